@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seefs001/openai-api-mock/mock"
+)
+
+// optionsFromEnv returns the server options configured by the MOCK_*
+// environment variables.
+func optionsFromEnv() mock.Options {
+	rpm := parsePositiveInt(os.Getenv("MOCK_RATE_LIMIT_RPM"), 0)
+	return mock.Options{
+		Latency:              parseDuration(os.Getenv("MOCK_LATENCY"), 0),
+		ModelLatency:         parseModelLatency(os.Getenv("MOCK_MODEL_LATENCY")),
+		FailRate:             parseRate(os.Getenv("MOCK_FAIL_RATE"), mock.DefaultFailRate),
+		FailCodes:            parseStatusCodes(os.Getenv("MOCK_FAIL_CODES"), nil),
+		FailEvery:            parsePositiveInt(os.Getenv("MOCK_FAIL_EVERY"), 0),
+		RetryAfter:           parsePositiveInt(os.Getenv("MOCK_RETRY_AFTER"), mock.DefaultRetryAfter),
+		ChunkSize:            parsePositiveInt(os.Getenv("MOCK_CHUNK_SIZE"), mock.DefaultStreamChunkSize),
+		StreamMode:           getenv("MOCK_STREAM_MODE", "rune"),
+		StreamInterval:       parseMillis(os.Getenv("MOCK_STREAM_INTERVAL_MS"), mock.StreamResponseInterval),
+		TTFT:                 parseMillis(os.Getenv("MOCK_TTFT_MS"), 0),
+		KeepAliveInterval:    parseMillis(os.Getenv("MOCK_KEEPALIVE_MS"), mock.DefaultKeepAliveInterval),
+		RuneDelay:            parseMillis(os.Getenv("MOCK_MS_PER_RUNE"), 0),
+		MaxStreams:           parsePositiveInt(os.Getenv("MOCK_MAX_STREAMS"), 0),
+		MaxBodyBytes:         int64(parsePositiveInt(os.Getenv("MOCK_MAX_BODY_BYTES"), mock.DefaultMaxBodyBytes)),
+		EmbeddingDimensions:  parsePositiveInt(os.Getenv("MOCK_EMBEDDING_DIMENSIONS"), mock.DefaultEmbeddingDimensions),
+		SystemFingerprint:    getenv("MOCK_SYSTEM_FINGERPRINT", mock.DefaultSystemFingerprint),
+		CreatedOffset:        parseInt(os.Getenv("MOCK_CREATED_OFFSET"), 0),
+		RateLimitRequests:    parsePositiveInt(os.Getenv("MOCK_RATELIMIT_REQUESTS"), mock.DefaultRateLimitRequests),
+		RateLimitTokens:      parsePositiveInt(os.Getenv("MOCK_RATELIMIT_TOKENS"), mock.DefaultRateLimitTokens),
+		RateLimitRPM:         rpm,
+		RateLimitCapacity:    parsePositiveInt(os.Getenv("MOCK_RATE_LIMIT_CAPACITY"), rpm),
+		BlockedUsers:         parseList(os.Getenv("MOCK_BLOCKED_USERS")),
+		APIKey:               os.Getenv("MOCK_API_KEY"),
+		Organization:         os.Getenv("MOCK_ORGANIZATION"),
+		AdminToken:           os.Getenv("MOCK_ADMIN_TOKEN"),
+		CORSOrigin:           getenv("MOCK_CORS_ORIGIN", "*"),
+		Models:               parseList(os.Getenv("MOCK_MODELS")),
+		MaxContext:           parsePositiveInt(os.Getenv("MOCK_MAX_CONTEXT"), 0),
+		StrictRoles:          parseBool(os.Getenv("MOCK_STRICT_ROLES")),
+		StrictFields:         parseBool(os.Getenv("MOCK_STRICT_FIELDS")),
+		VariedResponses:      parseBool(os.Getenv("MOCK_VARIED_RESPONSES")),
+		ResponsePrefix:       os.Getenv("MOCK_SYSTEM_PROMPT_PREFIX"),
+		RefusalTriggers:      parseList(os.Getenv("MOCK_REFUSAL_TRIGGERS")),
+		ModerationTriggers:   parseModerationTriggers(os.Getenv("MOCK_MODERATION_TRIGGERS")),
+		TranscriptionText:    os.Getenv("MOCK_TRANSCRIPTION_TEXT"),
+		PromptCacheMinTokens: parsePositiveInt(os.Getenv("MOCK_PROMPT_CACHE_MIN_TOKENS"), mock.DefaultPromptCacheMinTokens),
+		PromptCacheSize:      parsePositiveInt(os.Getenv("MOCK_PROMPT_CACHE_SIZE"), mock.DefaultPromptCacheSize),
+		BatchDuration:        parseDuration(os.Getenv("MOCK_BATCH_DURATION"), mock.DefaultBatchDuration),
+		RequestHistorySize:   parsePositiveInt(os.Getenv("MOCK_REQUEST_HISTORY"), mock.DefaultRequestHistorySize),
+	}
+}
+
+// getenv returns the value of the environment variable key, or def when unset.
+func getenv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// parseInt parses value as an integer, returning def when the value is empty
+// or invalid.
+func parseInt(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// parsePositiveInt parses value as a positive integer, returning def when the
+// value is empty or invalid.
+func parsePositiveInt(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// parseDuration parses value as a non-negative duration such as "250ms",
+// returning def when the value is empty or invalid.
+func parseDuration(value string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// parseMillis parses value as a non-negative number of milliseconds,
+// returning def milliseconds when the value is empty or invalid.
+func parseMillis(value string, def int) time.Duration {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		n = def
+	}
+	return time.Duration(n) * time.Millisecond
+}
+
+// parseModelLatency parses value as a JSON object of model names to
+// milliseconds, returning nil when the value is empty or invalid.
+func parseModelLatency(value string) map[string]int {
+	var latencies map[string]int
+	if json.Unmarshal([]byte(value), &latencies) != nil {
+		return nil
+	}
+	return latencies
+}
+
+// parseBool parses value as a boolean such as "true" or "1", treating empty
+// or invalid values as false.
+func parseBool(value string) bool {
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// parseRate parses value as a probability between 0 and 1, returning def when
+// the value is empty, invalid or out of range.
+func parseRate(value string, def float64) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return def
+	}
+	return f
+}
+
+// parseList splits a comma-separated value, dropping empty entries.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseStatusCodes parses a comma-separated list of 4xx/5xx status codes,
+// returning def when no valid code is found.
+func parseStatusCodes(value string, def []int) []int {
+	var codes []int
+	for _, field := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || code < 400 || code > 599 {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return def
+	}
+	return codes
+}
+
+// parseModerationTriggers parses MOCK_MODERATION_TRIGGERS, a comma-separated
+// list of "term" or "term:category" entries mapping lowercase substrings to
+// the category they flag. The category defaults to harassment.
+func parseModerationTriggers(value string) map[string]string {
+	triggers := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		term, category, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if term == "" {
+			continue
+		}
+		if !found || category == "" {
+			category = "harassment"
+		}
+		triggers[strings.ToLower(term)] = category
+	}
+	return triggers
+}
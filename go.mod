@@ -1,3 +1,14 @@
 module github.com/seefs001/openai-api-mock
 
 go 1.23.3
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/pkoukk/tiktoken-go v0.1.8
+	github.com/pkoukk/tiktoken-go-loader v0.0.2
+)
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+)
@@ -1,278 +1,112 @@
 package main
 
 import (
-	"compress/gzip"
-	"encoding/json"
-	"io"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"log/slog"
-	"math/rand"
+	"net"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
-)
 
-const (
-	StreamResponseInterval = 50
+	"github.com/seefs001/openai-api-mock/mock"
 )
 
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type ChatCompletionRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
-}
-
-type ChatCompletionResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Model   string `json:"model"`
-	Choices []struct {
-		Index   int     `json:"index"`
-		Message Message `json:"message"`
-	} `json:"choices"`
-}
-
-type DeltaMessage struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
-}
-
-type ChatCompletionChunk struct {
-	ID                string `json:"id"`
-	Object            string `json:"object"`
-	Created           int64  `json:"created"`
-	Model             string `json:"model"`
-	SystemFingerprint string `json:"system_fingerprint"`
-	Choices           []struct {
-		Index        int          `json:"index"`
-		Delta        DeltaMessage `json:"delta"`
-		LogProbs     interface{}  `json:"logprobs"`
-		FinishReason string       `json:"finish_reason,omitempty"`
-	} `json:"choices"`
-}
-
 func main() {
-	http.HandleFunc("/v1/chat/completions", handleChatCompletion)
-	http.HandleFunc("/rand_sleep/v1/chat/completions", handleRandomSleep)
-	http.HandleFunc("/rand_fail/v1/chat/completions", handleRandomFail)
-	http.HandleFunc("/rand_all/v1/chat/completions", handleRandom)
-	http.ListenAndServe(":5000", nil)
-}
-
-func handleRandomSleep(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
-	handleChatCompletion(w, r)
-}
-
-func handleRandomFail(w http.ResponseWriter, r *http.Request) {
-	if rand.Intn(10) < 7 {
-		http.Error(w, "Random error", http.StatusInternalServerError)
-		return
-	}
-	handleChatCompletion(w, r)
-}
-
-func handleRandom(w http.ResponseWriter, r *http.Request) {
-	if rand.Intn(10) < 5 {
-		handleRandomFail(w, r)
-		return
+	opts := optionsFromEnv()
+	addr := flag.String("addr", getenv("LISTEN_ADDR", ":5000"), "listen address, e.g. :8080 or 127.0.0.1:8080")
+	flag.DurationVar(&opts.Latency, "latency", opts.Latency, "fixed delay before every response, e.g. 500ms")
+	fixturesPath := flag.String("fixtures", os.Getenv("MOCK_FIXTURES"), "JSON fixtures file for record/replay of chat completions")
+	fixturesMode := flag.String("fixtures-mode", getenv("MOCK_FIXTURES_MODE", mock.FixtureModeReplay), "fixtures mode: replay or record")
+	sentencesPath := flag.String("sentences", os.Getenv("MOCK_SENTENCES_FILE"), "file of replies, one per line, picked by hashing the conversation")
+	templateDir := flag.String("template-dir", os.Getenv("MOCK_TEMPLATE_DIR"), "directory of *.tmpl response templates")
+	tlsCert := flag.String("tls-cert", os.Getenv("MOCK_TLS_CERT"), "TLS certificate file; serves HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", os.Getenv("MOCK_TLS_KEY"), "TLS private key file")
+	tlsSelfSigned := flag.Bool("tls-self-signed", false, "serve HTTPS with a generated self-signed certificate")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "time to wait for in-flight requests on shutdown")
+	flag.Parse()
+
+	if *templateDir != "" {
+		templates, err := mock.LoadTemplates(*templateDir)
+		if err != nil {
+			slog.Error("load templates", "dir", *templateDir, "err", err)
+			os.Exit(1)
+		}
+		opts.Templates = templates
 	}
-	time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
-	handleChatCompletion(w, r)
-}
-
-func handleChatCompletion(w http.ResponseWriter, r *http.Request) {
-
-	if r.Header.Get("Content-Encoding") == "gzip" {
-		gzipReader, err := gzip.NewReader(r.Body)
+	if *sentencesPath != "" {
+		sentences, err := mock.LoadSentences(*sentencesPath)
 		if err != nil {
-			http.Error(w, "Failed to decompress request body", http.StatusBadRequest)
-			return
+			slog.Error("load sentences", "path", *sentencesPath, "err", err)
+			os.Exit(1)
 		}
-		defer gzipReader.Close()
-
-		// Replace the request body with the decompressed data
-		r.Body = io.NopCloser(gzipReader)
+		opts.Sentences = sentences
 	}
-
-	// Continue processing the request
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	if *fixturesPath != "" {
+		store, err := mock.LoadFixtures(*fixturesPath, *fixturesMode)
+		if err != nil {
+			slog.Error("load fixtures", "path", *fixturesPath, "err", err)
+			os.Exit(1)
+		}
+		opts.Fixtures = store
 	}
 
-	var req ChatCompletionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	handler := mock.NewServer(opts)
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: handler,
 	}
 
-	slog.Info("handleChatCompletion", "req", req, "stream", req.Stream)
-	if req.Stream {
-		handleStreamingResponse(w, req)
-		return
+	if (*tlsCert == "") != (*tlsKey == "") {
+		slog.Error("-tls-cert and -tls-key must be set together")
+		os.Exit(1)
 	}
-	handleNonStreamingResponse(w, req)
-}
-
-func handleNonStreamingResponse(w http.ResponseWriter, req ChatCompletionRequest) {
-	response := ChatCompletionResponse{
-		ID:      "chatcmpl-" + randomString(10),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []struct {
-			Index   int     `json:"index"`
-			Message Message `json:"message"`
-		}{
-			{
-				Index: 0,
-				Message: Message{
-					Role:    "assistant",
-					Content: generateResponse(req.Messages),
-				},
-			},
-		},
+	useTLS := *tlsCert != "" || *tlsSelfSigned
+	if *tlsSelfSigned && *tlsCert == "" {
+		cert, err := selfSignedCert()
+		if err != nil {
+			slog.Error("generate self-signed certificate", "err", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func handleStreamingResponse(w http.ResponseWriter, req ChatCompletionRequest) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	response := generateResponse(req.Messages)
-	for i := 0; i < 10; i++ {
-		response += " " + generateResponse(req.Messages)
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		slog.Error("listen failed", "addr", *addr, "err", err)
+		os.Exit(1)
 	}
-	id := "chatcmpl-" + randomString(10)
-	created := time.Now().Unix()
 
-	// Send initial chunk with role
-	initialChunk := ChatCompletionChunk{
-		ID:                id,
-		Object:            "chat.completion.chunk",
-		Created:           created,
-		Model:             req.Model,
-		SystemFingerprint: "fp_44709d6fcb",
-		Choices: []struct {
-			Index        int          `json:"index"`
-			Delta        DeltaMessage `json:"delta"`
-			LogProbs     interface{}  `json:"logprobs"`
-			FinishReason string       `json:"finish_reason,omitempty"`
-		}{
-			{
-				Index: 0,
-				Delta: DeltaMessage{
-					Role: "assistant",
-				},
-				LogProbs:     nil,
-				FinishReason: "",
-			},
-		},
-	}
-
-	writeChunk(w, initialChunk)
-
-	// Send two characters at a time
-	runes := []rune(response)
-	for i := 0; i < len(runes); i += 2 {
-		var content string
-		if i+1 < len(runes) {
-			content = string(runes[i : i+2])
+	go func() {
+		slog.Info("listening", "addr", ln.Addr().String(), "tls", useTLS)
+		var err error
+		if useTLS {
+			err = server.ServeTLS(ln, *tlsCert, *tlsKey)
 		} else {
-			content = string(runes[i:])
+			err = server.Serve(ln)
 		}
-
-		chunk := ChatCompletionChunk{
-			ID:                id,
-			Object:            "chat.completion.chunk",
-			Created:           created,
-			Model:             req.Model,
-			SystemFingerprint: "fp_44709d6fcb",
-			Choices: []struct {
-				Index        int          `json:"index"`
-				Delta        DeltaMessage `json:"delta"`
-				LogProbs     interface{}  `json:"logprobs"`
-				FinishReason string       `json:"finish_reason,omitempty"`
-			}{
-				{
-					Index: 0,
-					Delta: DeltaMessage{
-						Content: content,
-					},
-					LogProbs:     nil,
-					FinishReason: "",
-				},
-			},
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped", "err", err)
+			os.Exit(1)
 		}
-
-		writeChunk(w, chunk)
-		time.Sleep(time.Duration(StreamResponseInterval) * time.Millisecond)
-	}
-
-	// Send final chunk
-	finalChunk := ChatCompletionChunk{
-		ID:                id,
-		Object:            "chat.completion.chunk",
-		Created:           created,
-		Model:             req.Model,
-		SystemFingerprint: "fp_44709d6fcb",
-		Choices: []struct {
-			Index        int          `json:"index"`
-			Delta        DeltaMessage `json:"delta"`
-			LogProbs     interface{}  `json:"logprobs"`
-			FinishReason string       `json:"finish_reason,omitempty"`
-		}{
-			{
-				Index:        0,
-				Delta:        DeltaMessage{},
-				LogProbs:     nil,
-				FinishReason: "stop",
-			},
-		},
+	}()
+
+	<-ctx.Done()
+	stop()
+	handler.SetReady(false)
+	slog.Info("shutting down", "timeout", *shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown failed", "err", err)
+		server.Close()
 	}
-
-	writeChunk(w, finalChunk)
-	w.Write([]byte("data: [DONE]\n\n"))
-}
-
-func writeChunk(w http.ResponseWriter, chunk interface{}) {
-	data := "data: " + toJSON(chunk) + "\n\n"
-	w.Write([]byte(data))
-	slog.Info("writeChunk", "chunk", chunk)
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
-	}
-}
-
-func generateResponse(messages []Message) string {
-	return "who are you? and what are you doing here? and what is your purpose?"
-}
-
-func splitIntoWords(s string) []string {
-	return strings.Fields(s)
-}
-
-func randomString(n int) string {
-	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
-
-func toJSON(v interface{}) string {
-	b, _ := json.Marshal(v)
-	return string(b)
 }
@@ -0,0 +1,186 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// speechPerRune is how much audio is generated per input character.
+	speechPerRune = 60 * time.Millisecond
+	// speechSampleRate matches the 24kHz 16-bit mono PCM the real API returns.
+	speechSampleRate = 24000
+	maxSpeechInput   = 4096
+)
+
+type SpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+var speechContentTypes = map[string]string{
+	"mp3": "audio/mpeg",
+	"wav": "audio/wav",
+	"pcm": "audio/pcm",
+}
+
+type TranscriptionResponse struct {
+	Text string `json:"text"`
+}
+
+func (s *Server) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			writeBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		writeError(w, http.StatusBadRequest,
+			"Could not parse multipart form: "+err.Error(),
+			"invalid_request_error", "")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'file'.",
+			Param:   "file",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+	file.Close()
+	if r.FormValue("model") == "" {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'model'.",
+			Param:   "model",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+
+	text := s.opts.TranscriptionText
+	if text == "" {
+		text = textFromFilename(header.Filename)
+	}
+
+	if r.FormValue("response_format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text + "\n"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TranscriptionResponse{Text: text})
+}
+
+// textFromFilename turns a file name like "hello_world.mp3" into "hello world".
+func textFromFilename(name string) string {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	return strings.Join(strings.FieldsFunc(base, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	}), " ")
+}
+
+func (s *Server) handleSpeech(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req SpeechRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Input == "" {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'input'.",
+			Param:   "input",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+	if len([]rune(req.Input)) > maxSpeechInput {
+		writeRequestError(w, &RequestError{
+			Message: fmt.Sprintf("'input' is too long. The maximum is %d characters.", maxSpeechInput),
+			Param:   "input",
+		})
+		return
+	}
+	if req.ResponseFormat == "" {
+		req.ResponseFormat = "mp3"
+	}
+	contentType, ok := speechContentTypes[req.ResponseFormat]
+	if !ok {
+		writeRequestError(w, &RequestError{
+			Message: fmt.Sprintf("Unsupported value: '%s'. The mock supports 'mp3', 'wav', and 'pcm'.", req.ResponseFormat),
+			Param:   "response_format",
+			Code:    "unsupported_value",
+		})
+		return
+	}
+
+	duration := time.Duration(len([]rune(req.Input))) * speechPerRune
+	var audio []byte
+	switch req.ResponseFormat {
+	case "mp3":
+		audio = silentMP3(duration)
+	case "wav":
+		audio = silentWAV(duration)
+	case "pcm":
+		audio = silentPCM(duration)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(audio)
+}
+
+// silentPCM returns duration worth of 16-bit mono silence at speechSampleRate.
+func silentPCM(duration time.Duration) []byte {
+	samples := int(duration.Seconds() * speechSampleRate)
+	return make([]byte, samples*2)
+}
+
+// silentWAV wraps silentPCM in a RIFF/WAVE header.
+func silentWAV(duration time.Duration) []byte {
+	pcm := silentPCM(duration)
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))                 // fmt chunk size
+	binary.Write(&buf, binary.LittleEndian, uint16(1))                  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(1))                  // mono
+	binary.Write(&buf, binary.LittleEndian, uint32(speechSampleRate))   // sample rate
+	binary.Write(&buf, binary.LittleEndian, uint32(speechSampleRate*2)) // byte rate
+	binary.Write(&buf, binary.LittleEndian, uint16(2))                  // block align
+	binary.Write(&buf, binary.LittleEndian, uint16(16))                 // bits per sample
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// silentMP3 returns enough silent MPEG-1 Layer III frames (128kbps, 44.1kHz,
+// mono) to cover duration. Each frame holds 1152 samples.
+func silentMP3(duration time.Duration) []byte {
+	const frameSize = 417
+	frame := make([]byte, frameSize)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+
+	frames := max(int(duration.Seconds()*44100/1152), 1)
+	return bytes.Repeat(frame, frames)
+}
@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAPIKey rejects requests whose bearer token does not match APIKey.
+func (s *Server) requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.APIKey == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(w, http.StatusUnauthorized,
+				"You didn't provide an API key. You need to provide your API key in an Authorization header using Bearer auth (i.e. Authorization: Bearer YOUR_KEY).",
+				"invalid_request_error", "invalid_api_key")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.APIKey)) != 1 {
+			writeError(w, http.StatusUnauthorized,
+				"Incorrect API key provided: "+maskAPIKey(token)+".",
+				"invalid_request_error", "invalid_api_key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireOrganization rejects requests whose OpenAI-Organization header does
+// not match Organization.
+func (s *Server) requireOrganization(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.Organization == "" {
+			next(w, r)
+			return
+		}
+
+		org := r.Header.Get("OpenAI-Organization")
+		if org == "" {
+			writeError(w, http.StatusUnauthorized,
+				"You must send an OpenAI-Organization header to use this API.",
+				"invalid_request_error", "invalid_organization")
+			return
+		}
+		if org != s.opts.Organization {
+			writeError(w, http.StatusUnauthorized,
+				"No such organization: "+org+".",
+				"invalid_request_error", "invalid_organization")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// maskAPIKey hides all but the edges of key, the way the real API does.
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + strings.Repeat("*", len(key)-7) + key[len(key)-4:]
+}
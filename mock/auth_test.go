@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	ts := newTestServer(t, Options{APIKey: "sk-test-123456"})
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Basic sk-test-123456", http.StatusUnauthorized},
+		{"wrong", "Bearer sk-test-654321", http.StatusUnauthorized},
+		{"prefix of the key", "Bearer sk-test", http.StatusUnauthorized},
+		{"correct", "Bearer sk-test-123456", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.authorization != "" {
+				header["Authorization"] = tt.authorization
+			}
+			resp, data := do(t, http.MethodGet, ts.URL+"/v1/models", "", header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && errorCode(t, data) != "invalid_api_key" {
+				t.Errorf("code = %q, want invalid_api_key", errorCode(t, data))
+			}
+		})
+	}
+
+	// Probes stay reachable without a key
+	if resp, _ := do(t, http.MethodGet, ts.URL+"/healthz", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz: status %d, want 200", resp.StatusCode)
+	}
+}
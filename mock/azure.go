@@ -0,0 +1,43 @@
+package mock
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// requireAzureAPIKey rejects requests whose api-key header does not match
+// APIKey, the way Azure OpenAI authenticates.
+func (s *Server) requireAzureAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.APIKey == "" {
+			next(w, r)
+			return
+		}
+
+		key := r.Header.Get("api-key")
+		if key == "" {
+			writeError(w, http.StatusUnauthorized,
+				"Access denied due to missing subscription key. Make sure to include subscription key when making requests to an API.",
+				"invalid_request_error", "invalid_api_key")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.opts.APIKey)) != 1 {
+			writeError(w, http.StatusUnauthorized,
+				"Access denied due to invalid subscription key or wrong API endpoint. Make sure to provide a valid key for an active subscription and use a correct regional API endpoint for your resource.",
+				"invalid_request_error", "invalid_api_key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAzureChatCompletion serves chat completions on the Azure OpenAI path,
+// where the model is the deployment name in the URL rather than the body.
+func (s *Server) handleAzureChatCompletion(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeChatCompletionRequest(w, r)
+	if !ok {
+		return
+	}
+	req.Model = r.PathValue("deployment")
+	s.serveChatCompletion(w, r, req)
+}
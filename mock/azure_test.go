@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAzureChatCompletion(t *testing.T) {
+	ts := newTestServer(t, Options{APIKey: "azure-key", Models: []string{"gpt-4o"}})
+	url := ts.URL + "/openai/deployments/gpt-4o/chat/completions?api-version=2024-06-01"
+	body := `{"messages":[{"role":"user","content":"hi"}]}`
+
+	resp, data := do(t, http.MethodPost, url, body, map[string]string{"api-key": "azure-key"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var completion ChatCompletionResponse
+	decode(t, data, &completion)
+	if completion.Model != "gpt-4o" {
+		t.Errorf("model = %q, want the deployment name", completion.Model)
+	}
+
+	for name, header := range map[string]map[string]string{
+		"missing key":   nil,
+		"wrong key":     {"api-key": "nope"},
+		"bearer header": {"Authorization": "Bearer azure-key"},
+	} {
+		if resp, _ := do(t, http.MethodPost, url, body, header); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, resp.StatusCode)
+		}
+	}
+
+	resp, _ = do(t, http.MethodPost, ts.URL+"/openai/deployments/nope/chat/completions", body, map[string]string{"api-key": "azure-key"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown deployment: status %d, want 404", resp.StatusCode)
+	}
+}
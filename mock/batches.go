@@ -0,0 +1,391 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchEndpoints are the endpoints a batch may target.
+var batchEndpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/responses"}
+
+type BatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type Batch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	Errors           interface{}        `json:"errors"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     *string            `json:"output_file_id"`
+	ErrorFileID      *string            `json:"error_file_id"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     *int64             `json:"in_progress_at"`
+	ExpiresAt        int64              `json:"expires_at"`
+	FinalizingAt     *int64             `json:"finalizing_at"`
+	CompletedAt      *int64             `json:"completed_at"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata"`
+
+	created time.Time
+}
+
+// batchStore keeps the batches created since startup or the last reset.
+type batchStore struct {
+	mu      sync.Mutex
+	batches map[string]Batch
+}
+
+func (s *batchStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.batches)
+}
+
+func (s *batchStore) add(b Batch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[b.ID] = b
+}
+
+// get returns the batch with the given id, passed through advance and stored
+// again so its progress sticks.
+func (s *batchStore) get(id string, advance func(Batch) Batch) (Batch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[id]
+	if !ok {
+		return Batch{}, false
+	}
+	b = advance(b)
+	s.batches[id] = b
+	return b, true
+}
+
+// advanceBatch moves b from validating to in_progress on the first poll and
+// to completed once BatchDuration has passed since it was created, filling in
+// the timestamps, output and error files of each step. Batches report
+// in_progress until then instead of taking the real 24 hour window.
+func (s *Server) advanceBatch(log *slog.Logger, b Batch, now time.Time) Batch {
+	if b.Status == "validating" {
+		t := s.unixTime(now)
+		b.Status = "in_progress"
+		b.InProgressAt = &t
+	}
+	if b.Status == "in_progress" && now.Sub(b.created) >= s.opts.BatchDuration {
+		t := s.unixTime(now)
+		output, errs, failed := s.batchResults(log, b)
+		s.files.add(output)
+		b.OutputFileID = &output.ID
+		if errs != nil {
+			s.files.add(*errs)
+			b.ErrorFileID = &errs.ID
+		}
+		b.Status = "completed"
+		b.FinalizingAt = &t
+		b.CompletedAt = &t
+		b.RequestCounts.Completed = b.RequestCounts.Total - failed
+		b.RequestCounts.Failed = failed
+	}
+	return b
+}
+
+// BatchInputLine is one request in a batch input file.
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// BatchOutputLine is the result of one request in a batch output or error
+// file. Requests that were answered carry a Response, even when it is an
+// error; lines that could not be read at all carry an Error instead.
+type BatchOutputLine struct {
+	ID       string               `json:"id"`
+	CustomID string               `json:"custom_id"`
+	Response *BatchOutputResponse `json:"response"`
+	Error    *BatchLineError      `json:"error"`
+}
+
+type BatchOutputResponse struct {
+	StatusCode int         `json:"status_code"`
+	RequestID  string      `json:"request_id"`
+	Body       interface{} `json:"body"`
+}
+
+type BatchLineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonLines returns the non-blank lines of a JSONL file.
+func jsonLines(content []byte) [][]byte {
+	var lines [][]byte
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// batchResults answers every line of b's input file as the batch endpoint
+// would. Successful responses go to the output file and everything else to
+// the error file, which is nil when no request failed.
+func (s *Server) batchResults(log *slog.Logger, b Batch) (output storedFile, errs *storedFile, failed int) {
+	input, _ := s.files.get(b.InputFileID)
+	rng := newRand(nil)
+	var out, errOut bytes.Buffer
+	for _, line := range jsonLines(input.content) {
+		result := BatchOutputLine{ID: "batch_req_" + randomString(rng, 24)}
+		var in BatchInputLine
+		if err := json.Unmarshal(line, &in); err != nil {
+			result.Error = &BatchLineError{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON."}
+		} else {
+			status, body := s.batchResponse(log, b.Endpoint, in.Body)
+			result.CustomID = in.CustomID
+			result.Response = &BatchOutputResponse{
+				StatusCode: status,
+				RequestID:  "req_" + randomString(rng, 24),
+				Body:       body,
+			}
+		}
+		if result.Response != nil && result.Response.StatusCode == http.StatusOK {
+			out.WriteString(toJSON(result) + "\n")
+		} else {
+			errOut.WriteString(toJSON(result) + "\n")
+			failed++
+		}
+	}
+	output = s.newBatchFile(rng, b.ID+"_output.jsonl", out.Bytes())
+	if failed > 0 {
+		f := s.newBatchFile(rng, b.ID+"_error.jsonl", errOut.Bytes())
+		errs = &f
+	}
+	return output, errs, failed
+}
+
+func (s *Server) newBatchFile(rng *rand.Rand, filename string, content []byte) storedFile {
+	return storedFile{
+		File: File{
+			ID:        "file-" + randomString(rng, 24),
+			Object:    "file",
+			Bytes:     len(content),
+			CreatedAt: s.unixTime(time.Now()),
+			Filename:  filename,
+			Purpose:   "batch_output",
+			Status:    "processed",
+		},
+		content: content,
+	}
+}
+
+// batchResponse returns the status and body endpoint answers the request body
+// of one batch line with, validating it the way the endpoint itself does.
+func (s *Server) batchResponse(log *slog.Logger, endpoint string, body json.RawMessage) (int, interface{}) {
+	rng := newRand(nil)
+	created := s.unixTime(time.Now())
+	invalid := func(err *RequestError) (int, interface{}) {
+		return http.StatusBadRequest, err.body()
+	}
+	notFound := func(model string) (int, interface{}) {
+		return http.StatusNotFound, (&RequestError{
+			Message: fmt.Sprintf("The model `%s` does not exist or you do not have access to it.", model),
+			Param:   "model",
+			Code:    "model_not_found",
+		}).body()
+	}
+	undecodable := &RequestError{Message: "We could not parse the JSON body of your request. Please make sure it is valid JSON."}
+
+	switch endpoint {
+	case "/v1/embeddings":
+		var req EmbeddingRequest
+		if json.Unmarshal(body, &req) != nil {
+			return invalid(undecodable)
+		}
+		if !s.modelAllowed(req.Model) {
+			return notFound(req.Model)
+		}
+		if err := validateEmbedding(req); err != nil {
+			return invalid(err)
+		}
+		return http.StatusOK, s.newEmbeddingResponse(req)
+	case "/v1/completions":
+		var req CompletionRequest
+		if json.Unmarshal(body, &req) != nil {
+			return invalid(undecodable)
+		}
+		if !s.modelAllowed(req.Model) {
+			return notFound(req.Model)
+		}
+		if err := validateStop(req.Stop); err != nil {
+			return invalid(err)
+		}
+		messages := []Message{{Role: "user", Content: TextContent(req.Prompt)}}
+		text, finishReason := applyMaxTokens(req.Model, applyStop(s.generateResponse(log, ChatCompletionRequest{
+			Model:    req.Model,
+			Messages: messages,
+		}), req.Stop), req.MaxTokens)
+		usage := calculateUsage(req.Model, messages, text)
+		return http.StatusOK, CompletionResponse{
+			ID:      "cmpl-" + randomString(rng, 10),
+			Object:  "text_completion",
+			Created: created,
+			Model:   req.Model,
+			Choices: []CompletionChoice{{Text: text, FinishReason: finishReason}},
+			Usage:   &usage,
+		}
+	case "/v1/responses":
+		var req ResponsesRequest
+		if json.Unmarshal(body, &req) != nil {
+			return invalid(undecodable)
+		}
+		if !s.modelAllowed(req.Model) {
+			return notFound(req.Model)
+		}
+		if len(req.Input) == 0 {
+			return invalid(&RequestError{
+				Message: "Missing required parameter: 'input'.",
+				Param:   "input",
+				Code:    "missing_required_parameter",
+			})
+		}
+		return http.StatusOK, s.newResponse(log, req, "", 0)
+	default:
+		var req ChatCompletionRequest
+		if json.Unmarshal(body, &req) != nil {
+			return invalid(undecodable)
+		}
+		if !s.modelAllowed(req.Model) {
+			return notFound(req.Model)
+		}
+		if err := s.validateChatCompletion(req); err != nil {
+			return invalid(err)
+		}
+		content, finishReason := applyMaxTokens(req.Model, applyStop(s.generateContent(log, req), req.Stop), req.completionLimit())
+		return http.StatusOK, ChatCompletionResponse{
+			ID:                "chatcmpl-" + randomString(rng, 10),
+			Object:            "chat.completion",
+			Created:           created,
+			Model:             req.Model,
+			SystemFingerprint: s.opts.SystemFingerprint,
+			Choices: []ChatCompletionChoice{{
+				Message:      Message{Role: "assistant", Content: TextContent(content)},
+				FinishReason: finishReason,
+			}},
+			Usage: calculateUsage(req.Model, req.Messages, content),
+		}
+	}
+}
+
+func (s *Server) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	for _, p := range []struct{ name, value string }{
+		{"input_file_id", req.InputFileID},
+		{"endpoint", req.Endpoint},
+		{"completion_window", req.CompletionWindow},
+	} {
+		if p.value == "" {
+			writeParamError(w, http.StatusBadRequest,
+				fmt.Sprintf("Missing required parameter: '%s'.", p.name),
+				"invalid_request_error", p.name, "missing_required_parameter")
+			return
+		}
+	}
+	if !slices.Contains(batchEndpoints, req.Endpoint) {
+		writeParamError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid value: '%s'. Supported values are: '%s'.", req.Endpoint, strings.Join(batchEndpoints, "', '")),
+			"invalid_request_error", "endpoint", "invalid_value")
+		return
+	}
+	if req.CompletionWindow != "24h" {
+		writeParamError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid value: '%s'. Supported values are: '24h'.", req.CompletionWindow),
+			"invalid_request_error", "completion_window", "invalid_value")
+		return
+	}
+	input, ok := s.files.get(req.InputFileID)
+	if !ok {
+		writeRequestError(w, &RequestError{
+			Message: fmt.Sprintf("Invalid 'input_file_id': '%s'. No such file.", req.InputFileID),
+			Param:   "input_file_id",
+			Code:    "invalid_value",
+		})
+		return
+	}
+
+	now := time.Now()
+	b := Batch{
+		ID:               "batch_" + randomString(newRand(nil), 24),
+		Object:           "batch",
+		Endpoint:         req.Endpoint,
+		InputFileID:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           "validating",
+		CreatedAt:        s.unixTime(now),
+		ExpiresAt:        s.unixTime(now.Add(24 * time.Hour)),
+		RequestCounts:    BatchRequestCounts{Total: len(jsonLines(input.content))},
+		Metadata:         req.Metadata,
+		created:          now,
+	}
+	s.batches.add(b)
+	requestLogger(r.Context()).Info("batch created", "id", b.ID, "endpoint", b.Endpoint, "input_file_id", b.InputFileID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+func (s *Server) handleRetrieveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	id := r.PathValue("id")
+	b, ok := s.batches.get(id, func(b Batch) Batch {
+		return s.advanceBatch(requestLogger(r.Context()), b, time.Now())
+	})
+	if !ok {
+		writeError(w, http.StatusNotFound,
+			fmt.Sprintf("No batch found with id '%s'.", id),
+			"invalid_request_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
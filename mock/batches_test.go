@@ -0,0 +1,214 @@
+package mock
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// uploadFile uploads content as a file with purpose and returns it.
+func uploadFile(t *testing.T, url, purpose, content string) File {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("purpose", purpose)
+	part, err := form.CreateFormFile("file", "input.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+
+	resp, data := do(t, http.MethodPost, url+"/v1/files", body.String(), map[string]string{"Content-Type": form.FormDataContentType()})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: status %d: %s", resp.StatusCode, data)
+	}
+	var f File
+	decode(t, data, &f)
+	return f
+}
+
+// createBatch creates a batch of the chat requests in input and returns it.
+func createBatch(t *testing.T, url, input string) Batch {
+	t.Helper()
+	f := uploadFile(t, url, "batch", input)
+	resp, data := do(t, http.MethodPost, url+"/v1/batches",
+		`{"input_file_id":"`+f.ID+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create batch: status %d: %s", resp.StatusCode, data)
+	}
+	var b Batch
+	decode(t, data, &b)
+	return b
+}
+
+// getBatch retrieves the batch with the given id.
+func getBatch(t *testing.T, url, id string) Batch {
+	t.Helper()
+	resp, data := do(t, http.MethodGet, url+"/v1/batches/"+id, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("get batch: status %d: %s", resp.StatusCode, data)
+	}
+	var b Batch
+	decode(t, data, &b)
+	return b
+}
+
+const batchInput = `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}}
+{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[{"role":"user","content":"yo"}]}}
+`
+
+func TestBatchLifecycle(t *testing.T) {
+	ts := newTestServer(t, Options{BatchDuration: 50 * time.Millisecond})
+
+	b := createBatch(t, ts.URL, batchInput)
+	if b.Status != "validating" || b.Object != "batch" || b.RequestCounts.Total != 2 {
+		t.Fatalf("new batch = %+v, want validating with 2 requests", b)
+	}
+
+	b = getBatch(t, ts.URL, b.ID)
+	if b.Status != "in_progress" || b.InProgressAt == nil || b.OutputFileID != nil {
+		t.Fatalf("first poll = %+v, want in_progress without output", b)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	b = getBatch(t, ts.URL, b.ID)
+	if b.Status != "completed" || b.CompletedAt == nil || b.OutputFileID == nil {
+		t.Fatalf("after BatchDuration = %+v, want completed with output", b)
+	}
+	if b.RequestCounts != (BatchRequestCounts{Total: 2, Completed: 2}) {
+		t.Errorf("request_counts = %+v, want 2 completed", b.RequestCounts)
+	}
+}
+
+func TestCreateBatchValidation(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	f := uploadFile(t, ts.URL, "batch", batchInput)
+	tests := []struct {
+		name      string
+		body      string
+		wantParam string
+	}{
+		{"missing input file", `{"endpoint":"/v1/chat/completions","completion_window":"24h"}`, "input_file_id"},
+		{"unknown input file", `{"input_file_id":"file-nope","endpoint":"/v1/chat/completions","completion_window":"24h"}`, "input_file_id"},
+		{"unsupported endpoint", `{"input_file_id":"` + f.ID + `","endpoint":"/v1/models","completion_window":"24h"}`, "endpoint"},
+		{"unsupported window", `{"input_file_id":"` + f.ID + `","endpoint":"/v1/chat/completions","completion_window":"1h"}`, "completion_window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, http.MethodPost, ts.URL+"/v1/batches", tt.body, nil)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", resp.StatusCode, data)
+			}
+			var e ErrorResponse
+			decode(t, data, &e)
+			if e.Error.Param == nil || *e.Error.Param != tt.wantParam {
+				t.Errorf("param = %v, want %s", e.Error.Param, tt.wantParam)
+			}
+		})
+	}
+
+	if resp, _ := do(t, http.MethodGet, ts.URL+"/v1/batches/batch_nope", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown batch: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// batchLines returns the lines of the batch file with the given id.
+func batchLines(t *testing.T, url, id string) []BatchOutputLine {
+	t.Helper()
+	resp, data := do(t, http.MethodGet, url+"/v1/files/"+id+"/content", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("file content: status %d: %s", resp.StatusCode, data)
+	}
+	var lines []BatchOutputLine
+	for _, line := range jsonLines(data) {
+		var l BatchOutputLine
+		decode(t, line, &l)
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// completeBatch polls the batch until BatchDuration has passed and returns
+// it completed.
+func completeBatch(t *testing.T, url, id string) Batch {
+	t.Helper()
+	getBatch(t, url, id)
+	time.Sleep(10 * time.Millisecond)
+	b := getBatch(t, url, id)
+	if b.Status != "completed" {
+		t.Fatalf("batch = %+v, want completed", b)
+	}
+	return b
+}
+
+func TestBatchOutputFile(t *testing.T) {
+	ts := newTestServer(t, Options{BatchDuration: time.Millisecond})
+	b := completeBatch(t, ts.URL, createBatch(t, ts.URL, batchInput).ID)
+	if b.ErrorFileID != nil {
+		t.Errorf("error_file_id = %s, want none when every request succeeded", *b.ErrorFileID)
+	}
+
+	lines := batchLines(t, ts.URL, *b.OutputFileID)
+	if len(lines) != 2 || lines[0].CustomID != "a" || lines[1].CustomID != "b" {
+		t.Fatalf("output lines = %+v, want a and b", lines)
+	}
+	for _, line := range lines {
+		if line.Response == nil || line.Response.StatusCode != http.StatusOK || line.Error != nil {
+			t.Errorf("line %s = %+v, want a 200 response", line.CustomID, line)
+		}
+	}
+}
+
+func TestBatchInvalidLines(t *testing.T) {
+	ts := newTestServer(t, Options{BatchDuration: time.Millisecond})
+	input := batchInput +
+		// json_schema without a schema used to panic every poll
+		`{"custom_id":"schema","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_schema"}}}` + "\n" +
+		`{"custom_id":"n","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","n":0,"messages":[{"role":"user","content":"hi"}]}}` + "\n" +
+		`{"custom_id":"max_tokens","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","max_tokens":0,"messages":[{"role":"user","content":"hi"}]}}` + "\n" +
+		`{"custom_id":"messages","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[]}}` + "\n" +
+		"not json\n"
+
+	b := completeBatch(t, ts.URL, createBatch(t, ts.URL, input).ID)
+	if b.RequestCounts != (BatchRequestCounts{Total: 7, Completed: 2, Failed: 5}) {
+		t.Errorf("request_counts = %+v, want 2 completed and 5 failed", b.RequestCounts)
+	}
+	if got := len(batchLines(t, ts.URL, *b.OutputFileID)); got != 2 {
+		t.Errorf("output file has %d lines, want 2", got)
+	}
+	if b.ErrorFileID == nil {
+		t.Fatal("error_file_id is missing")
+	}
+
+	params := map[string]string{}
+	for _, line := range batchLines(t, ts.URL, *b.ErrorFileID) {
+		if line.Response == nil {
+			if line.Error == nil || line.Error.Code != "invalid_json_line" {
+				t.Errorf("unparseable line = %+v, want an invalid_json_line error", line)
+			}
+			continue
+		}
+		if line.Response.StatusCode != http.StatusBadRequest {
+			t.Errorf("line %s has status %d, want 400", line.CustomID, line.Response.StatusCode)
+		}
+		var body ErrorResponse
+		decode(t, []byte(toJSON(line.Response.Body)), &body)
+		if body.Error.Param != nil {
+			params[line.CustomID] = *body.Error.Param
+		}
+	}
+	want := map[string]string{
+		"schema":     "response_format.json_schema",
+		"n":          "n",
+		"max_tokens": "max_tokens",
+		"messages":   "messages",
+	}
+	for id, param := range want {
+		if params[id] != param {
+			t.Errorf("line %s failed on %q, want %q", id, params[id], param)
+		}
+	}
+}
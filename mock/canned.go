@@ -0,0 +1,23 @@
+package mock
+
+// SetCannedResponse registers response as the reply to any conversation whose
+// last user message is exactly prompt.
+func (s *Server) SetCannedResponse(prompt, response string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.CannedResponses[prompt] = response
+}
+
+// lookupCannedResponse returns the canned reply for the last user message.
+func (s *Server) lookupCannedResponse(messages []Message) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		response, ok := s.opts.CannedResponses[messages[i].Content.String()]
+		return response, ok
+	}
+	return "", false
+}
@@ -0,0 +1,843 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StreamResponseInterval      = 50
+	DefaultStreamChunkSize      = 2
+	DefaultEmbeddingDimensions  = 1536
+	DefaultFailRate             = 0.7
+	DefaultRetryAfter           = 1
+	DefaultRateLimitRequests    = 10000
+	DefaultRateLimitTokens      = 2000000
+	DefaultPromptCacheMinTokens = 1024
+	DefaultPromptCacheSize      = 1000
+	DefaultKeepAliveInterval    = 15000
+	DefaultMaxBodyBytes         = 8 << 20
+	DefaultRequestHistorySize   = 100
+	DefaultBatchDuration        = 10 * time.Second
+	DefaultSystemFingerprint    = "fp_44709d6fcb"
+
+	// EchoModel makes generateResponse return the user messages verbatim.
+	EchoModel = "echo"
+
+	// RefuseModel makes the assistant always respond with a refusal.
+	RefuseModel = "refuse"
+
+	DefaultResponse = "who are you? and what are you doing here? and what is your purpose?"
+
+	// DetailedUsageHeader, set to true on a request, makes the response carry
+	// the same header with a JSON array of the token count of each message.
+	DetailedUsageHeader = "x-mock-detailed-usage"
+)
+
+// Message is one turn of a conversation. Content is nil for an assistant
+// turn that only made tool calls.
+type Message struct {
+	Role       string          `json:"role"`
+	Name       string          `json:"name,omitempty"`
+	Content    *MessageContent `json:"content"`
+	Refusal    *string         `json:"refusal,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// StringOrSlice decodes a JSON value that may be either a single string or an
+// array of strings.
+type StringOrSlice []string
+
+func (s *StringOrSlice) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringOrSlice{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*s = multiple
+	return nil
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type ChatCompletionRequest struct {
+	Model               string             `json:"model"`
+	Messages            []Message          `json:"messages"`
+	Stream              bool               `json:"stream"`
+	StreamOptions       *StreamOptions     `json:"stream_options,omitempty"`
+	MaxTokens           *int               `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int               `json:"max_completion_tokens,omitempty"`
+	N                   *int               `json:"n,omitempty"`
+	Tools               []Tool             `json:"tools,omitempty"`
+	ToolChoice          *ToolChoice        `json:"tool_choice,omitempty"`
+	ParallelToolCalls   *bool              `json:"parallel_tool_calls,omitempty"`
+	Seed                *int               `json:"seed,omitempty"`
+	ResponseFormat      *ResponseFormat    `json:"response_format,omitempty"`
+	Stop                StringOrSlice      `json:"stop,omitempty"`
+	LogProbs            bool               `json:"logprobs,omitempty"`
+	TopLogProbs         *int               `json:"top_logprobs,omitempty"`
+	User                string             `json:"user,omitempty"`
+	LogitBias           map[string]float64 `json:"logit_bias,omitempty"`
+
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+
+	// Template is the response template requested via TemplateHeader.
+	Template string `json:"-"`
+	// PadTokens is the number of filler words requested with ?pad_tokens=.
+	PadTokens int `json:"-"`
+	// FinishReason replaces the generated finish reason when set with
+	// ?finish_reason=.
+	FinishReason string `json:"-"`
+}
+
+// completionLimit returns max_completion_tokens, falling back to the
+// deprecated max_tokens when only that is set.
+func (req ChatCompletionRequest) completionLimit() *int {
+	if req.MaxCompletionTokens != nil {
+		return req.MaxCompletionTokens
+	}
+	return req.MaxTokens
+}
+
+// samplingParams returns the sampling parameters set on the request, keyed by
+// their JSON names.
+func (req ChatCompletionRequest) samplingParams() map[string]float64 {
+	params := map[string]float64{}
+	for name, value := range map[string]*float64{
+		"temperature":       req.Temperature,
+		"top_p":             req.TopP,
+		"presence_penalty":  req.PresencePenalty,
+		"frequency_penalty": req.FrequencyPenalty,
+	} {
+		if value != nil {
+			params[name] = *value
+		}
+	}
+	return params
+}
+
+type Usage struct {
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	PromptTokensDetails     *PromptTokensDetails     `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+type ChatCompletionChoice struct {
+	Index                int                            `json:"index"`
+	Message              Message                        `json:"message"`
+	LogProbs             *LogProbs                      `json:"logprobs"`
+	FinishReason         string                         `json:"finish_reason"`
+	ContentFilterResults map[string]ContentFilterResult `json:"content_filter_results,omitempty"`
+}
+
+type ChatCompletionResponse struct {
+	ID                string                 `json:"id"`
+	Object            string                 `json:"object"`
+	Created           int64                  `json:"created"`
+	Model             string                 `json:"model"`
+	SystemFingerprint string                 `json:"system_fingerprint"`
+	Choices           []ChatCompletionChoice `json:"choices"`
+	Usage             Usage                  `json:"usage"`
+}
+
+type DeltaMessage struct {
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Refusal   *string    `json:"refusal,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// emptyContent encodes an empty Content as "" instead of omitting it.
+	emptyContent bool
+}
+
+func (d DeltaMessage) MarshalJSON() ([]byte, error) {
+	type delta DeltaMessage
+	if !d.emptyContent || d.Content != "" {
+		return json.Marshal(delta(d))
+	}
+	return json.Marshal(struct {
+		delta
+		Content string `json:"content"`
+	}{delta: delta(d)})
+}
+
+// text returns the generated text carried by the delta, whether content,
+// refusal or tool call arguments.
+func (d DeltaMessage) text() string {
+	text := d.Content
+	if d.Refusal != nil {
+		text += *d.Refusal
+	}
+	for _, call := range d.ToolCalls {
+		text += call.Function.Name + call.Function.Arguments
+	}
+	return text
+}
+
+type ChatCompletionChunkChoice struct {
+	Index                int                            `json:"index"`
+	Delta                DeltaMessage                   `json:"delta"`
+	LogProbs             *LogProbs                      `json:"logprobs"`
+	FinishReason         string                         `json:"finish_reason,omitempty"`
+	ContentFilterResults map[string]ContentFilterResult `json:"content_filter_results,omitempty"`
+}
+
+type ChatCompletionChunk struct {
+	ID                string                      `json:"id"`
+	Object            string                      `json:"object"`
+	Created           int64                       `json:"created"`
+	Model             string                      `json:"model"`
+	SystemFingerprint string                      `json:"system_fingerprint"`
+	Choices           []ChatCompletionChunkChoice `json:"choices"`
+	Usage             *Usage                      `json:"usage,omitempty"`
+}
+
+func (s *Server) handleRandomSleep(w http.ResponseWriter, r *http.Request) {
+	s.handleChatCompletion(w, withRandomDelay(r))
+}
+
+func (s *Server) handleRandomFail(w http.ResponseWriter, r *http.Request) {
+	if rand.Float64() < s.failRate(r) {
+		s.writeRandomFailure(w)
+		return
+	}
+	s.handleChatCompletion(w, r)
+}
+
+// handleRandom sends half of the requests through handleRandomFail and
+// delays the other half, so they fail at half the FailRate.
+func (s *Server) handleRandom(w http.ResponseWriter, r *http.Request) {
+	if rand.Intn(2) == 0 {
+		s.handleRandomFail(w, r)
+		return
+	}
+	s.handleChatCompletion(w, withRandomDelay(r))
+}
+
+type delayKey struct{}
+
+// withRandomDelay attaches a random delay of up to five seconds to r. It is
+// served before the response, which for streams happens after the headers so
+// keep-alive comments can flow in the meantime.
+func withRandomDelay(r *http.Request) *http.Request {
+	d := time.Duration(rand.Intn(5000)) * time.Millisecond
+	return r.WithContext(context.WithValue(r.Context(), delayKey{}, d))
+}
+
+// requestDelay returns the delay attached by withRandomDelay, if any.
+func requestDelay(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(delayKey{}).(time.Duration)
+	return d
+}
+
+func (s *Server) writeRandomFailure(w http.ResponseWriter) {
+	codes := s.failCodes()
+	s.writeStatusError(w, codes[rand.Intn(len(codes))])
+}
+
+func (s *Server) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeChatCompletionRequest(w, r)
+	if !ok {
+		return
+	}
+	s.serveChatCompletion(w, r, req)
+}
+
+// decodeChatCompletionRequest reads the chat request from r, writing an error
+// response and returning false when it cannot be decoded.
+func (s *Server) decodeChatCompletionRequest(w http.ResponseWriter, r *http.Request) (ChatCompletionRequest, bool) {
+	var req ChatCompletionRequest
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return req, false
+	}
+	if !requireJSON(w, r) {
+		return req, false
+	}
+	if !decompressBody(w, r, s.opts.MaxBodyBytes) {
+		return req, false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	if s.opts.StrictFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&req); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			field = strings.Trim(field, `"`)
+			writeParamError(w, http.StatusBadRequest,
+				"Unrecognized request argument supplied: "+field,
+				"invalid_request_error", field, "unknown_parameter")
+			return req, false
+		}
+		writeDecodeError(w, err)
+		return req, false
+	}
+	return req, true
+}
+
+// serveChatCompletion validates a decoded chat request and writes the
+// completion, streamed or not.
+func (s *Server) serveChatCompletion(w http.ResponseWriter, r *http.Request, req ChatCompletionRequest) {
+	if ok, retryAfter := s.allowUser(req.User); !ok {
+		s.writeRateLimited(w, retryAfter)
+		return
+	}
+	if !s.modelAllowed(req.Model) {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	if err := s.validateChatCompletion(req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	params := req.samplingParams()
+	req.Template = r.Header.Get(TemplateHeader)
+	req.PadTokens = padTokens(r)
+	req.FinishReason = finishReasonOverride(r)
+
+	log := requestLogger(r.Context()).With("model", req.Model)
+	log.Info("handleChatCompletion", "req", req, "stream", req.Stream, "user", req.User, "params", params, "logit_bias", req.LogitBias)
+	if req.MaxTokens != nil {
+		log.Warn("max_tokens is deprecated in favor of max_completion_tokens", "max_tokens", *req.MaxTokens)
+	}
+	if !sleepCtx(r.Context(), s.modelLatency(req.Model)) {
+		return
+	}
+	w.Header().Set("x-mock-echo-params", toJSON(params))
+	if parseBool(r.Header.Get(DetailedUsageHeader)) {
+		w.Header().Set(DetailedUsageHeader, toJSON(messageTokens(req.Model, req.Messages)))
+	}
+	if req.Stream {
+		s.handleStreamingResponse(w, r, log, req)
+		return
+	}
+	if !sleepCtx(r.Context(), requestDelay(r.Context())) {
+		return
+	}
+	s.handleNonStreamingResponse(w, log, req)
+}
+
+func (s *Server) handleNonStreamingResponse(w http.ResponseWriter, log *slog.Logger, req ChatCompletionRequest) {
+	// Describe what was received, so tests can assert on it without the body
+	w.Header().Set("x-mock-prompt-tokens", strconv.Itoa(calculateUsage(req.Model, req.Messages, "").PromptTokens))
+	w.Header().Set("x-mock-message-count", strconv.Itoa(len(req.Messages)))
+
+	if response, ok := s.opts.Fixtures.lookup(req); ok {
+		log.Info("fixture replayed", "id", response.ID)
+		s.recordUsage(w.Header(), response.Usage.TotalTokens)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	rng := newRand(req.Seed)
+	n := choiceCount(req.N)
+	choices := make([]ChatCompletionChoice, 0, n)
+	completion := ""
+	for i := 0; i < n; i++ {
+		if refusal, ok := s.generateRefusal(req); ok {
+			choices = append(choices, ChatCompletionChoice{
+				Index: i,
+				Message: Message{
+					Role:    "assistant",
+					Refusal: &refusal,
+				},
+				FinishReason: "stop",
+			})
+			completion += " " + refusal
+			continue
+		}
+		if toolCalls := s.generateToolCalls(req, rng); len(toolCalls) > 0 {
+			choices = append(choices, ChatCompletionChoice{
+				Index: i,
+				Message: Message{
+					Role:      "assistant",
+					ToolCalls: toolCalls,
+				},
+				FinishReason: "tool_calls",
+			})
+			for _, call := range toolCalls {
+				completion += " " + call.Function.Name + " " + call.Function.Arguments
+			}
+			continue
+		}
+
+		content, finishReason := applyMaxTokens(req.Model, applyStop(s.generateContent(log, req), req.Stop), req.completionLimit())
+		choice := ChatCompletionChoice{
+			Index: i,
+			Message: Message{
+				Role:    "assistant",
+				Content: TextContent(content),
+			},
+			FinishReason: finishReason,
+		}
+		if req.LogProbs {
+			choice.LogProbs = generateLogProbs(splitWordsWithSpaces(content), topLogProbs(req))
+		}
+		choices = append(choices, choice)
+		completion += " " + content
+	}
+	if req.FinishReason != "" {
+		for i := range choices {
+			choices[i].FinishReason = req.FinishReason
+			choices[i].ContentFilterResults = contentFilterResults(req.FinishReason)
+		}
+	}
+
+	response := ChatCompletionResponse{
+		ID:                "chatcmpl-" + randomString(rng, 10),
+		Object:            "chat.completion",
+		Created:           s.unixTime(time.Now()),
+		Model:             req.Model,
+		SystemFingerprint: s.opts.SystemFingerprint,
+		Choices:           choices,
+		Usage:             s.chatUsage(req, completion),
+	}
+	if err := s.opts.Fixtures.record(req, response); err != nil {
+		log.Error("record fixture", "err", err)
+	}
+
+	s.recordUsage(w.Header(), response.Usage.TotalTokens)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// streamedChoice is the content of one choice in a streamed response.
+type streamedChoice struct {
+	deltas       []DeltaMessage
+	completion   string
+	finishReason string
+}
+
+// generateStreamedChoice builds the deltas streamed for one choice of req.
+func (s *Server) generateStreamedChoice(r *http.Request, req ChatCompletionRequest, rng *rand.Rand) streamedChoice {
+	var choice streamedChoice
+	if refusal, ok := s.generateRefusal(req); ok {
+		for _, piece := range s.streamPieces(r, refusal) {
+			choice.deltas = append(choice.deltas, DeltaMessage{Refusal: &piece})
+		}
+		choice.completion, choice.finishReason = refusal, "stop"
+		return choice
+	}
+	if toolCalls := s.generateToolCalls(req, rng); len(toolCalls) > 0 {
+		if streamChunks(r) == 1 {
+			choice.deltas = []DeltaMessage{wholeToolCallDelta(toolCalls)}
+		} else {
+			choice.deltas = toolCallDeltas(toolCalls, s.streamChunkSize(r))
+		}
+		for _, call := range toolCalls {
+			choice.completion += " " + call.Function.Name + " " + call.Function.Arguments
+		}
+		choice.finishReason = "tool_calls"
+		return choice
+	}
+
+	base := s.baseResponse(requestLogger(r.Context()), req)
+	if base == DefaultResponse {
+		// The default sentence is short, so repeat it to give the stream some length
+		base = strings.TrimSpace(strings.Repeat(DefaultResponse+" ", 11))
+	}
+	response := shapeContent(req, s.decorateResponse(req, base))
+	choice.completion, choice.finishReason = applyMaxTokens(req.Model, applyStop(response, req.Stop), req.completionLimit())
+	for _, content := range s.streamPieces(r, choice.completion) {
+		choice.deltas = append(choice.deltas, DeltaMessage{Content: content})
+	}
+	return choice
+}
+
+func (s *Server) handleStreamingResponse(w http.ResponseWriter, r *http.Request, log *slog.Logger, req ChatCompletionRequest) {
+	rng := newRand(req.Seed)
+	n := choiceCount(req.N)
+
+	choices := make([]streamedChoice, n)
+	completion := ""
+	steps := 0
+	for index := range choices {
+		choices[index] = s.generateStreamedChoice(r, req, rng)
+		if req.FinishReason != "" {
+			choices[index].finishReason = req.FinishReason
+		}
+		completion += choices[index].completion + " "
+		steps = max(steps, len(choices[index].deltas))
+	}
+	usage := s.chatUsage(req, completion)
+
+	release, ok := s.acquireStream()
+	if !ok {
+		s.writeStreamLimited(w)
+		return
+	}
+	defer release()
+
+	s.recordUsage(w.Header(), usage.TotalTokens)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	id := "chatcmpl-" + randomString(rng, 10)
+	created := s.unixTime(time.Now())
+	stream := s.newChunkStream(w, r, log, id, created)
+
+	if !stream.wait(requestDelay(r.Context()) + s.ttft(r)) {
+		return
+	}
+
+	// Send initial chunk with role
+	for index := 0; index < n; index++ {
+		stream.write(s.newChunk(id, created, req.Model, index, DeltaMessage{Role: "assistant"}, ""))
+		for i := 0; i < emptyChunks(r); i++ {
+			stream.write(s.newChunk(id, created, req.Model, index, DeltaMessage{emptyContent: true}, ""))
+		}
+	}
+
+	// Interleave the choices one delta at a time. Each choice gets its final
+	// chunk as soon as it runs out of deltas, while longer ones carry on.
+	for step := 0; step <= steps; step++ {
+		text := ""
+		for index, choice := range choices {
+			switch {
+			case step < len(choice.deltas):
+				delta := choice.deltas[step]
+				chunk := s.newChunk(id, created, req.Model, index, delta, "")
+				if req.LogProbs && delta.Content != "" {
+					chunk.Choices[0].LogProbs = generateLogProbs([]string{delta.Content}, topLogProbs(req))
+				}
+				stream.write(chunk)
+				if t := delta.text(); len(t) > len(text) {
+					text = t
+				}
+			case step == len(choice.deltas):
+				chunk := s.newChunk(id, created, req.Model, index, DeltaMessage{}, choice.finishReason)
+				chunk.Choices[0].ContentFilterResults = contentFilterResults(choice.finishReason)
+				stream.write(chunk)
+			}
+		}
+		if step == steps {
+			break
+		}
+		if !sleepCtx(r.Context(), s.chunkDelay(r, text)) {
+			log.Info("stream cancelled", "id", id, "sent", step+1, "total", steps)
+			return
+		}
+	}
+
+	// Send usage chunk, which carries no choices
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		usageChunk := s.newChunk(id, created, req.Model, 0, DeltaMessage{}, "")
+		usageChunk.Choices = []ChatCompletionChunkChoice{}
+		usageChunk.Usage = &usage
+		stream.write(usageChunk)
+	}
+	stream.done()
+}
+
+func (s *Server) newChunk(id string, created int64, model string, index int, delta DeltaMessage, finishReason string) ChatCompletionChunk {
+	return ChatCompletionChunk{
+		ID:                id,
+		Object:            "chat.completion.chunk",
+		Created:           created,
+		Model:             model,
+		SystemFingerprint: s.opts.SystemFingerprint,
+		Choices: []ChatCompletionChunkChoice{
+			{
+				Index:        index,
+				Delta:        delta,
+				LogProbs:     nil,
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+// generateContent returns the assistant content for req, shaped to match the
+// requested response format.
+func (s *Server) generateContent(log *slog.Logger, req ChatCompletionRequest) string {
+	return shapeContent(req, s.generateResponse(log, req))
+}
+
+// shapeContent shapes response to match the response format requested by req.
+func shapeContent(req ChatCompletionRequest, response string) string {
+	if req.ResponseFormat == nil {
+		return response
+	}
+	switch req.ResponseFormat.Type {
+	case "json_object":
+		return toJSONObject(response)
+	case "json_schema":
+		if req.ResponseFormat.JSONSchema == nil {
+			return toJSONObject(response)
+		}
+		return toJSON(sampleFromSchema(req.ResponseFormat.JSONSchema.Schema))
+	}
+	return response
+}
+
+// generateResponse returns the reply text for req, starting with
+// ResponsePrefix and padded with filler words when requested.
+func (s *Server) generateResponse(log *slog.Logger, req ChatCompletionRequest) string {
+	return s.decorateResponse(req, s.baseResponse(log, req))
+}
+
+// decorateResponse starts base with ResponsePrefix and pads it with filler
+// words when req asks for them.
+func (s *Server) decorateResponse(req ChatCompletionRequest, base string) string {
+	return padResponse(s.opts.ResponsePrefix+base, req.PadTokens)
+}
+
+func (s *Server) baseResponse(log *slog.Logger, req ChatCompletionRequest) string {
+	if response, ok := s.lookupCannedResponse(req.Messages); ok {
+		return response
+	}
+	if response, ok := s.renderTemplate(log, req); ok {
+		return response
+	}
+	if req.Model == EchoModel {
+		return echoUserMessages(req.Messages)
+	}
+	if images := countImages(req.Messages); images > 0 {
+		return describeImages(images) + " " + s.defaultResponse(req)
+	}
+	return s.defaultResponse(req)
+}
+
+// defaultResponse is the reply used when nothing more specific applies: a
+// sentence picked from the configured Sentences, a varied reply from the built-in
+// corpus, or DefaultResponse.
+func (s *Server) defaultResponse(req ChatCompletionRequest) string {
+	if sentence, ok := s.pickSentence(req); ok {
+		return sentence
+	}
+	if s.opts.VariedResponses {
+		return variedResponse(newRand(req.Seed))
+	}
+	return DefaultResponse
+}
+
+// fillerWords are cycled through to pad responses.
+var fillerWords = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor")
+
+// padResponse appends n filler words to response.
+func padResponse(response string, n int) string {
+	if n <= 0 {
+		return response
+	}
+	var b strings.Builder
+	b.WriteString(response)
+	for i := 0; i < n; i++ {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(fillerWords[i%len(fillerWords)])
+	}
+	return b.String()
+}
+
+// countImages returns the number of images attached to user messages.
+func countImages(messages []Message) int {
+	count := 0
+	for _, m := range messages {
+		if m.Role == "user" {
+			count += m.Content.ImageCount()
+		}
+	}
+	return count
+}
+
+func describeImages(count int) string {
+	if count == 1 {
+		return "I see 1 image."
+	}
+	return fmt.Sprintf("I see %d images.", count)
+}
+
+// lastUserMessage returns the text of the last user message.
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content.String()
+		}
+	}
+	return ""
+}
+
+// echoUserMessages concatenates the content of all user messages.
+func echoUserMessages(messages []Message) string {
+	var parts []string
+	for _, m := range messages {
+		if m.Role == "user" {
+			parts = append(parts, m.Content.String())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// sleepCtx pauses for d and reports whether ctx is still live afterwards,
+// returning early when it is cancelled.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// splitRunes splits s into pieces of at most size runes.
+func splitRunes(s string, size int) []string {
+	var pieces []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); i += size {
+		pieces = append(pieces, string(runes[i:min(i+size, len(runes))]))
+	}
+	return pieces
+}
+
+func splitIntoWords(s string) []string {
+	return strings.Fields(s)
+}
+
+// splitWordsWithSpaces splits s into words, keeping a trailing space on every
+// word but the last so the pieces concatenate back into the sentence.
+func splitWordsWithSpaces(s string) []string {
+	words := splitIntoWords(s)
+	for i := 0; i < len(words)-1; i++ {
+		words[i] += " "
+	}
+	return words
+}
+
+// choiceCount returns the number of choices requested via n, defaulting to 1.
+func choiceCount(n *int) int {
+	if n == nil || *n < 1 {
+		return 1
+	}
+	return *n
+}
+
+// applyStop cuts content at the first occurrence of any stop sequence,
+// excluding the sequence itself.
+func applyStop(content string, stop []string) string {
+	cut := len(content)
+	for _, seq := range stop {
+		if seq == "" {
+			continue
+		}
+		if i := strings.Index(content, seq); i >= 0 && i < cut {
+			cut = i
+		}
+	}
+	return content[:cut]
+}
+
+// applyMaxTokens truncates content to at most maxTokens tokens of model and
+// returns the resulting content together with the finish reason to report.
+func applyMaxTokens(model, content string, maxTokens *int) (string, string) {
+	if maxTokens == nil {
+		return content, "stop"
+	}
+	if content, cut := truncateTokens(model, content, *maxTokens); cut {
+		return content, "length"
+	}
+	return content, "stop"
+}
+
+// messageTokens returns the token count of each message, which together make
+// up the prompt tokens.
+func messageTokens(model string, messages []Message) []int {
+	tokens := make([]int, len(messages))
+	for i, m := range messages {
+		tokens[i] = countTokens(model, m.Content.String())
+	}
+	return tokens
+}
+
+func calculateUsage(model string, messages []Message, completion string) Usage {
+	promptTokens := 0
+	for _, n := range messageTokens(model, messages) {
+		promptTokens += n
+	}
+	completionTokens := countTokens(model, completion)
+	return Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// chatUsage computes the usage for a chat completion, including the prompt
+// tokens served from the simulated prompt cache and, for reasoning models,
+// the hidden reasoning tokens counted in completion_tokens.
+func (s *Server) chatUsage(req ChatCompletionRequest, completion string) Usage {
+	usage := calculateUsage(req.Model, req.Messages, completion)
+	usage.PromptTokensDetails = &PromptTokensDetails{
+		CachedTokens: s.promptCache.cachedTokens(req.Model, req.Messages),
+	}
+	reasoning := reasoningTokens(req.Model, usage.CompletionTokens)
+	usage.CompletionTokens += reasoning
+	usage.TotalTokens += reasoning
+	usage.CompletionTokensDetails = &CompletionTokensDetails{ReasoningTokens: reasoning}
+	return usage
+}
+
+// reasoningTokens simulates the tokens an o1 or o3 model spends thinking
+// before writing completionTokens of output: four times the output, in whole
+// blocks of 64 like the real API reports. Other models do not reason.
+func reasoningTokens(model string, completionTokens int) int {
+	if !strings.HasPrefix(model, "o1") && !strings.HasPrefix(model, "o3") {
+		return 0
+	}
+	return (completionTokens*4/64 + 1) * 64
+}
+
+// newRand returns a random source for a single request. When seed is set the
+// source is deterministic, so identical requests produce identical output.
+func newRand(seed *int) *rand.Rand {
+	if seed != nil {
+		return rand.New(rand.NewSource(int64(*seed)))
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+func randomString(rng *rand.Rand, n int) string {
+	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = letters[rng.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,221 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+// chat posts a chat completion request with params added to a single "hi"
+// user message and decodes the response, failing unless it is a 200.
+func chat(t *testing.T, url, params string) ChatCompletionResponse {
+	t.Helper()
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]`
+	if params != "" {
+		body += "," + params
+	}
+	resp, data := do(t, http.MethodPost, url+"/v1/chat/completions", body+"}", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var completion ChatCompletionResponse
+	decode(t, data, &completion)
+	return completion
+}
+
+// streamedContent joins the content deltas of choice 0 and returns it with
+// the finish reason that ended the choice.
+func streamedContent(chunks []ChatCompletionChunk) (string, string) {
+	content, finish := "", ""
+	for _, chunk := range chunks {
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Index != 0 {
+			continue
+		}
+		content += chunk.Choices[0].Delta.Content
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			finish = reason
+		}
+	}
+	return content, finish
+}
+
+func TestMaxTokens(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	completion := chat(t, ts.URL, `"max_tokens":3`)
+	if got := completion.Choices[0].FinishReason; got != "length" {
+		t.Errorf("finish_reason = %q, want length", got)
+	}
+	if got := completion.Usage.CompletionTokens; got != 3 {
+		t.Errorf("completion_tokens = %d, want 3", got)
+	}
+
+	completion = chat(t, ts.URL, `"max_tokens":1000`)
+	if got := completion.Choices[0].Message.Content.String(); got != DefaultResponse {
+		t.Errorf("content = %q, want the whole reply", got)
+	}
+	if got := completion.Choices[0].FinishReason; got != "stop" {
+		t.Errorf("finish_reason = %q, want stop", got)
+	}
+}
+
+func TestMaxTokensStream(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	_, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+		`{"model":"gpt-4o","stream":true,"max_tokens":4,"messages":[{"role":"user","content":"hi"}]}`, nil)
+
+	content, finish := streamedContent(readChunks(t, data))
+	if finish != "length" {
+		t.Errorf("finish_reason = %q, want length", finish)
+	}
+	if got := countTokens("gpt-4o", content); got != 4 {
+		t.Errorf("streamed %d tokens (%q), want 4", got, content)
+	}
+}
+
+func TestChoiceCount(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	completion := chat(t, ts.URL, `"n":3`)
+	if len(completion.Choices) != 3 {
+		t.Fatalf("got %d choices, want 3", len(completion.Choices))
+	}
+	for i, choice := range completion.Choices {
+		if choice.Index != i {
+			t.Errorf("choice %d has index %d", i, choice.Index)
+		}
+	}
+
+	for _, n := range []string{"0", "-1", "129"} {
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+			`{"model":"gpt-4o","n":`+n+`,"messages":[{"role":"user","content":"hi"}]}`, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("n=%s: status %d, want 400", n, resp.StatusCode)
+			continue
+		}
+		var e ErrorResponse
+		decode(t, data, &e)
+		if e.Error.Param == nil || *e.Error.Param != "n" {
+			t.Errorf("n=%s: param = %v, want n", n, e.Error.Param)
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"single sequence", `"stop":"?"`, "who are you"},
+		{"earliest of several", `"stop":["purpose","doing"]`, "who are you? and what are you "},
+		{"no match", `"stop":["zebra"]`, DefaultResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice := chat(t, ts.URL, tt.params).Choices[0]
+			if got := choice.Message.Content.String(); got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+			if choice.FinishReason != "stop" {
+				t.Errorf("finish_reason = %q, want stop", choice.FinishReason)
+			}
+		})
+	}
+
+	resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+		`{"model":"gpt-4o","stop":["a","b","c","d","e"],"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if resp.StatusCode != http.StatusBadRequest || errorCode(t, data) != "array_above_max_length" {
+		t.Errorf("five stop sequences: status %d: %s", resp.StatusCode, data)
+	}
+}
+
+func TestMaxCompletionTokens(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	// max_completion_tokens wins over the deprecated max_tokens
+	completion := chat(t, ts.URL, `"max_completion_tokens":5,"max_tokens":2`)
+	if got := completion.Choices[0].FinishReason; got != "length" {
+		t.Errorf("finish_reason = %q, want length", got)
+	}
+	if got := completion.Usage.CompletionTokens; got != 5 {
+		t.Errorf("completion_tokens = %d, want 5", got)
+	}
+}
+
+func TestStreamInterleavesChoices(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	_, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+		`{"model":"gpt-4o","n":2,"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`, nil)
+	chunks := readChunks(t, data)
+
+	var order []int
+	content := map[int]string{}
+	finishes := map[int]int{}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if len(chunk.Choices) != 1 {
+			t.Fatalf("chunk has %d choices, want 1", len(chunk.Choices))
+		}
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			order = append(order, choice.Index)
+			content[choice.Index] += choice.Delta.Content
+		}
+		if choice.FinishReason != "" {
+			if choice.Delta.Content != "" {
+				t.Errorf("choice %d finished in a chunk with content", choice.Index)
+			}
+			finishes[choice.Index]++
+		}
+	}
+
+	if len(order) == 0 || len(order)%2 != 0 {
+		t.Fatalf("got %d content chunks, want an even number above 0", len(order))
+	}
+	for i, index := range order {
+		if index != i%2 {
+			t.Fatalf("content chunk %d belongs to choice %d, want %d", i, index, i%2)
+		}
+	}
+	for index := 0; index < 2; index++ {
+		if content[index] == "" {
+			t.Errorf("choice %d streamed no content", index)
+		}
+		if finishes[index] != 1 {
+			t.Errorf("choice %d finished %d times, want once", index, finishes[index])
+		}
+	}
+
+	last := chunks[len(chunks)-1]
+	if len(last.Choices) != 0 || last.Usage == nil {
+		t.Errorf("last chunk = %+v, want a usage chunk without choices", last)
+	}
+}
+
+func TestStrictFields(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"temprature":0.5}`
+
+	ts := newTestServer(t, Options{})
+	if resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions", body, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("lenient: status %d: %s", resp.StatusCode, data)
+	}
+
+	ts = newTestServer(t, Options{StrictFields: true})
+	resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions", body, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("strict: status %d, want 400", resp.StatusCode)
+	}
+	var e ErrorResponse
+	decode(t, data, &e)
+	if e.Error.Param == nil || *e.Error.Param != "temprature" || errorCode(t, data) != "unknown_parameter" {
+		t.Errorf("strict: error = %s, want unknown_parameter for temprature", data)
+	}
+}
+
+func TestShapeContentWithoutSchema(t *testing.T) {
+	// Validation rejects this request, but generation must not crash on it
+	req := ChatCompletionRequest{ResponseFormat: &ResponseFormat{Type: "json_schema"}}
+	if got := shapeContent(req, "hi"); got != `{"response":"hi"}` {
+		t.Errorf("shapeContent = %s", got)
+	}
+}
@@ -0,0 +1,132 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type CompletionRequest struct {
+	Model     string        `json:"model"`
+	Prompt    string        `json:"prompt"`
+	Stream    bool          `json:"stream"`
+	MaxTokens *int          `json:"max_tokens,omitempty"`
+	Seed      *int          `json:"seed,omitempty"`
+	Stop      StringOrSlice `json:"stop,omitempty"`
+}
+
+type CompletionChoice struct {
+	Text         string      `json:"text"`
+	Index        int         `json:"index"`
+	LogProbs     interface{} `json:"logprobs"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+}
+
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   *Usage             `json:"usage,omitempty"`
+}
+
+func (s *Server) handleCompletion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req CompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if !s.modelAllowed(req.Model) {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+
+	if err := validateStop(req.Stop); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	// The legacy API takes a bare prompt, so wrap it as a user message to
+	// reuse the chat response generation.
+	log := requestLogger(r.Context()).With("model", req.Model)
+	messages := []Message{{Role: "user", Content: TextContent(req.Prompt)}}
+	text, finishReason := applyMaxTokens(req.Model, applyStop(s.generateResponse(log, ChatCompletionRequest{
+		Model:     req.Model,
+		Messages:  messages,
+		Template:  r.Header.Get(TemplateHeader),
+		PadTokens: padTokens(r),
+	}), req.Stop), req.MaxTokens)
+	if !sleepCtx(r.Context(), s.modelLatency(req.Model)) {
+		return
+	}
+	id := "cmpl-" + randomString(newRand(req.Seed), 10)
+	created := s.unixTime(time.Now())
+
+	if !req.Stream {
+		usage := calculateUsage(req.Model, messages, text)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CompletionResponse{
+			ID:      id,
+			Object:  "text_completion",
+			Created: created,
+			Model:   req.Model,
+			Choices: []CompletionChoice{
+				{Text: text, Index: 0, FinishReason: finishReason},
+			},
+			Usage: &usage,
+		})
+		return
+	}
+
+	release, ok := s.acquireStream()
+	if !ok {
+		s.writeStreamLimited(w)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	stream := s.newChunkStream(w, r, log, id, created)
+
+	if !stream.wait(s.ttft(r)) {
+		return
+	}
+
+	pieces := s.streamPieces(r, text)
+	for i, piece := range pieces {
+		stream.write(CompletionResponse{
+			ID:      id,
+			Object:  "text_completion",
+			Created: created,
+			Model:   req.Model,
+			Choices: []CompletionChoice{
+				{Text: piece, Index: 0},
+			},
+		})
+		if !sleepCtx(r.Context(), s.chunkDelay(r, piece)) {
+			log.Info("stream cancelled", "id", id, "sent", i+1, "total", len(pieces))
+			return
+		}
+	}
+
+	stream.write(CompletionResponse{
+		ID:      id,
+		Object:  "text_completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []CompletionChoice{
+			{Text: "", Index: 0, FinishReason: finishReason},
+		},
+	})
+	stream.done()
+}
@@ -0,0 +1,202 @@
+package mock
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DecompressError is returned while reading a request body that does not
+// match its Content-Encoding.
+type DecompressError struct {
+	Encoding string
+	Err      error
+}
+
+func (e *DecompressError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Encoding, e.Err)
+}
+
+func (e *DecompressError) Unwrap() error {
+	return e.Err
+}
+
+// decompressingReader reports read errors from a decompressor as a
+// *DecompressError, so corrupt bodies can be told apart from invalid JSON.
+type decompressingReader struct {
+	io.Reader
+	encoding string
+}
+
+func (r decompressingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = &DecompressError{Encoding: r.encoding, Err: err}
+	}
+	return n, err
+}
+
+// decompressBody replaces the body of r with its decompressed form according
+// to Content-Encoding, which may be gzip, deflate or br. It writes an error
+// and returns false when the encoding is unsupported or the body does not
+// start like one. The decompressed body is limited to limit bytes, like the
+// compressed one, so a small payload cannot inflate without bound.
+func decompressBody(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	encoding := contentEncoding(r)
+	if encoding == "" {
+		return true
+	}
+	body, ok, err := newDecompressor(encoding, r.Body)
+	if !ok {
+		writeError(w, http.StatusUnsupportedMediaType,
+			fmt.Sprintf("Unsupported Content-Encoding '%s'. Supported encodings are 'gzip', 'deflate' and 'br'.", encoding),
+			"invalid_request_error", "unsupported_content_encoding")
+		return false
+	}
+	if err != nil {
+		writeDecompressError(w, &DecompressError{Encoding: encoding, Err: err})
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, io.NopCloser(decompressingReader{body, encoding}), limit)
+	return true
+}
+
+// contentEncoding returns the normalised Content-Encoding of r, or "" when
+// the body is sent as is.
+func contentEncoding(r *http.Request) string {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
+
+// newDecompressor returns a reader decompressing body sent with the
+// Content-Encoding encoding. ok is false when the encoding is unsupported.
+func newDecompressor(encoding string, body io.Reader) (r io.Reader, ok bool, err error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = newDeflateReader(body)
+	case "br":
+		r = brotli.NewReader(body)
+	default:
+		return nil, false, nil
+	}
+	return r, true, err
+}
+
+// newDeflateReader decompresses a deflate body. HTTP defines deflate as
+// zlib-wrapped, but some clients send raw DEFLATE, so a body that does not
+// start with a zlib header is read as raw DEFLATE instead.
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// writeDecompressError writes a 400 for a body that is not valid for its
+// Content-Encoding.
+func writeDecompressError(w http.ResponseWriter, err *DecompressError) {
+	writeError(w, http.StatusBadRequest,
+		fmt.Sprintf("The request body could not be decompressed as %s: %v. Make sure the Content-Encoding header matches how the body is compressed.", err.Encoding, err.Err),
+		"invalid_request_error", "invalid_content_encoding")
+}
+
+// withGzip compresses JSON responses for clients that send
+// Accept-Encoding: gzip. Event streams are left alone so every chunk still
+// reaches the client as soon as it is flushed.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsEncoding(r, "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsEncoding reports whether r lists encoding in Accept-Encoding without
+// a zero quality value.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// gzipResponseWriter decides on the first write whether to compress, based on
+// the Content-Type the handler has set by then.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decide(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) decide(status int) {
+	w.decided = true
+	h := w.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" ||
+		!strings.HasPrefix(h.Get("Content-Type"), "application/json") {
+		return
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
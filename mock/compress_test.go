@@ -0,0 +1,115 @@
+package mock
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const chatBody = `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+
+// compress returns body compressed by the writer newWriter returns.
+func compress(t *testing.T, body string, newWriter func(io.Writer) io.WriteCloser) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func gzipWriter(w io.Writer) io.WriteCloser   { return gzip.NewWriter(w) }
+func zlibWriter(w io.Writer) io.WriteCloser   { return zlib.NewWriter(w) }
+func brotliWriter(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+
+// flateWriter writes raw DEFLATE without the zlib wrapper.
+func flateWriter(w io.Writer) io.WriteCloser {
+	fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+	return fw
+}
+
+func TestCompressedRequestBody(t *testing.T) {
+	ts := newTestServer(t, Options{MaxBodyBytes: 4096})
+	gzipped := compress(t, chatBody, gzipWriter)
+	// Leading whitespace is valid JSON, so the decoder keeps reading past
+	// the limit instead of stopping at the end of a small object
+	bomb := compress(t, strings.Repeat(" ", 1<<20)+chatBody, gzipWriter)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"gzip", "gzip", gzipped, http.StatusOK, ""},
+		{"x-gzip", "x-gzip", gzipped, http.StatusOK, ""},
+		{"deflate", "deflate", compress(t, chatBody, zlibWriter), http.StatusOK, ""},
+		{"raw deflate", "deflate", compress(t, chatBody, flateWriter), http.StatusOK, ""},
+		{"br", "br", compress(t, chatBody, brotliWriter), http.StatusOK, ""},
+		{"identity", "identity", chatBody, http.StatusOK, ""},
+		{"unsupported", "zstd", chatBody, http.StatusUnsupportedMediaType, "unsupported_content_encoding"},
+		{"not gzip", "gzip", chatBody, http.StatusBadRequest, "invalid_content_encoding"},
+		// Neither a zlib header nor a valid DEFLATE block type
+		{"not deflate", "deflate", "\xff" + chatBody, http.StatusBadRequest, "invalid_content_encoding"},
+		{"truncated gzip", "gzip", gzipped[:len(gzipped)/2], http.StatusBadRequest, "invalid_content_encoding"},
+		{"inflates past the limit", "gzip", bomb, http.StatusRequestEntityTooLarge, "request_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions", tt.body,
+				map[string]string{"Content-Encoding": tt.encoding})
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(t, data); code != tt.wantCode {
+					t.Errorf("code = %q, want %q", code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/models", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Setting Accept-Encoding by hand stops the transport from decompressing
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list ModelList
+	decode(t, data, &list)
+	if len(list.Data) == 0 {
+		t.Error("model list is empty")
+	}
+}
@@ -0,0 +1,171 @@
+package mock
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parsePositiveInt parses value as a positive integer, returning def when the
+// value is empty or invalid.
+func parsePositiveInt(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// parseNonNegativeInt parses value as an integer >= 0, returning def when the
+// value is empty or invalid.
+func parseNonNegativeInt(value string, def int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// parseMillis parses value as a non-negative number of milliseconds,
+// returning def milliseconds when the value is empty or invalid.
+func parseMillis(value string, def int) time.Duration {
+	return time.Duration(parseNonNegativeInt(value, def)) * time.Millisecond
+}
+
+// parseBool parses value as a boolean such as "true" or "1", treating empty
+// or invalid values as false.
+func parseBool(value string) bool {
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// parseRate parses value as a probability between 0 and 1, returning def when
+// the value is empty, invalid or out of range.
+func parseRate(value string, def float64) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return def
+	}
+	return f
+}
+
+func (s *Server) latency() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.opts.Latency
+}
+
+// modelLatency returns the ModelLatency delay for model. An exact name wins;
+// otherwise the longest wildcard name such as "gpt-4*" whose prefix model
+// starts with applies.
+func (s *Server) modelLatency(model string) time.Duration {
+	ms, ok := s.opts.ModelLatency[model]
+	if !ok {
+		match := ""
+		for name, d := range s.opts.ModelLatency {
+			prefix, wildcard := strings.CutSuffix(name, "*")
+			if wildcard && strings.HasPrefix(model, prefix) && len(prefix) >= len(match) {
+				match, ms = prefix, d
+			}
+		}
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond
+}
+
+func (s *Server) failRate(r *http.Request) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return parseRate(r.URL.Query().Get("fail_rate"), s.opts.FailRate)
+}
+
+func (s *Server) failCodes() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.opts.FailCodes
+}
+
+func (s *Server) failEvery() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.opts.FailEvery
+}
+
+func (s *Server) streamChunkSize(r *http.Request) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return parsePositiveInt(r.URL.Query().Get("chunk_size"), s.opts.ChunkSize)
+}
+
+func (s *Server) streamInterval(r *http.Request) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return queryMillis(r, "stream_interval_ms", s.opts.StreamInterval)
+}
+
+// chunkDelay returns the pause after streaming text: ms_per_rune for each of
+// its runes when set, otherwise the flat stream interval.
+func (s *Server) chunkDelay(r *http.Request, text string) time.Duration {
+	perRune := queryMillis(r, "ms_per_rune", s.opts.RuneDelay)
+	if perRune == 0 {
+		return s.streamInterval(r)
+	}
+	return perRune * time.Duration(utf8.RuneCountInString(text))
+}
+
+func (s *Server) keepAliveInterval(r *http.Request) time.Duration {
+	return queryMillis(r, "keepalive_ms", s.opts.KeepAliveInterval)
+}
+
+func (s *Server) ttft(r *http.Request) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return queryMillis(r, "ttft_ms", s.opts.TTFT)
+}
+
+// queryMillis returns the query parameter name of r as milliseconds, or def
+// when it is unset or invalid.
+func queryMillis(r *http.Request, name string, def time.Duration) time.Duration {
+	return parseMillis(r.URL.Query().Get(name), int(def/time.Millisecond))
+}
+
+// unixTime returns t as a Unix timestamp shifted by CreatedOffset, as
+// reported in responses.
+func (s *Server) unixTime(t time.Time) int64 {
+	return t.Unix() + int64(s.opts.CreatedOffset)
+}
+
+func padTokens(r *http.Request) int {
+	return parseNonNegativeInt(r.URL.Query().Get("pad_tokens"), 0)
+}
+
+// emptyChunks returns the number of chunks with empty content sent after the
+// role chunk, set per request with ?empty_chunks=, as the real API sometimes
+// does before the first real content.
+func emptyChunks(r *http.Request) int {
+	return parseNonNegativeInt(r.URL.Query().Get("empty_chunks"), 0)
+}
+
+// streamChunks returns the number of chunks requested with ?stream_chunks=,
+// or zero when the content should be split by stream mode as usual.
+func streamChunks(r *http.Request) int {
+	return parsePositiveInt(r.URL.Query().Get("stream_chunks"), 0)
+}
+
+// streamPieces splits content into the pieces streamed for r. With
+// ?stream_chunks=N it is split into at most N pieces of about equal length.
+func (s *Server) streamPieces(r *http.Request, content string) []string {
+	if n := streamChunks(r); n > 0 {
+		size := (utf8.RuneCountInString(content) + n - 1) / n
+		return splitRunes(content, max(size, 1))
+	}
+	mode := r.URL.Query().Get("stream_mode")
+	if mode == "" {
+		mode = s.opts.StreamMode
+	}
+	if mode == "word" {
+		return splitWordsWithSpaces(content)
+	}
+	return splitRunes(content, s.streamChunkSize(r))
+}
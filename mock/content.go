@@ -0,0 +1,78 @@
+package mock
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// MessageContent is the content of a message, which the API accepts either as
+// a plain string or as an array of content parts.
+type MessageContent struct {
+	Text  string
+	Parts []ContentPart
+}
+
+// TextContent returns plain string content.
+func TextContent(s string) *MessageContent {
+	return &MessageContent{Text: s}
+}
+
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	if c.Parts != nil {
+		return json.Marshal(c.Parts)
+	}
+	return json.Marshal(c.Text)
+}
+
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		c.Text = ""
+		return json.Unmarshal(data, &c.Parts)
+	}
+	c.Parts = nil
+	return json.Unmarshal(data, &c.Text)
+}
+
+// String returns the text of the content, joining the text parts when the
+// content is an array. Nil content, sent as null, is empty.
+func (c *MessageContent) String() string {
+	if c == nil {
+		return ""
+	}
+	if c.Parts == nil {
+		return c.Text
+	}
+	var texts []string
+	for _, p := range c.Parts {
+		if p.Type == "text" || p.Type == "input_text" || p.Type == "output_text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ImageCount returns the number of image_url parts in the content, or
+// input_image parts from the Responses API.
+func (c *MessageContent) ImageCount() int {
+	if c == nil {
+		return 0
+	}
+	count := 0
+	for _, p := range c.Parts {
+		if p.Type == "image_url" || p.Type == "input_image" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"net/http"
+	"slices"
+)
+
+// finishReasons are the values accepted by ?finish_reason=.
+var finishReasons = []string{"stop", "length", "tool_calls", "content_filter", "function_call"}
+
+// ContentFilterResult is the verdict of the content filter for one category,
+// as reported by Azure OpenAI.
+type ContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+}
+
+// finishReasonOverride returns the finish reason forced with ?finish_reason=,
+// or "" when none or an unknown one is given.
+func finishReasonOverride(r *http.Request) string {
+	reason := r.URL.Query().Get("finish_reason")
+	if !slices.Contains(finishReasons, reason) {
+		return ""
+	}
+	return reason
+}
+
+// contentFilterResults returns the content_filter_results reported with a
+// choice that finished for finishReason. A choice stopped by the content
+// filter has its hate category filtered at high severity; the others are
+// reported as safe.
+func contentFilterResults(finishReason string) map[string]ContentFilterResult {
+	if finishReason != "content_filter" {
+		return nil
+	}
+	return map[string]ContentFilterResult{
+		"hate":      {Filtered: true, Severity: "high"},
+		"self_harm": {Filtered: false, Severity: "safe"},
+		"sexual":    {Filtered: false, Severity: "safe"},
+		"violence":  {Filtered: false, Severity: "safe"},
+	}
+}
@@ -0,0 +1,128 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+)
+
+// maxEmbeddingDimensions is the largest dimensions value the API accepts, the
+// full size of text-embedding-3-large.
+const maxEmbeddingDimensions = 3072
+
+type EmbeddingRequest struct {
+	Model      string        `json:"model"`
+	Input      StringOrSlice `json:"input"`
+	Dimensions *int          `json:"dimensions,omitempty"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type EmbeddingResponse struct {
+	Object string         `json:"object"`
+	Data   []Embedding    `json:"data"`
+	Model  string         `json:"model"`
+	Usage  EmbeddingUsage `json:"usage"`
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if !s.modelAllowed(req.Model) {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+
+	if err := validateEmbedding(req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.newEmbeddingResponse(req))
+}
+
+// validateEmbedding checks that req has non-empty input and, when set,
+// dimensions between 1 and maxEmbeddingDimensions.
+func validateEmbedding(req EmbeddingRequest) *RequestError {
+	if len(req.Input) == 0 {
+		return &RequestError{Message: "'$.input' is invalid. The input must be a non-empty string or array of strings."}
+	}
+	for _, input := range req.Input {
+		if input == "" {
+			return &RequestError{Message: "'$.input' is invalid. Input strings must not be empty."}
+		}
+	}
+	if req.Dimensions != nil && (*req.Dimensions < 1 || *req.Dimensions > maxEmbeddingDimensions) {
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid value for 'dimensions' = %d. Must be between 1 and %d.", *req.Dimensions, maxEmbeddingDimensions),
+			Param:   "dimensions",
+			Code:    "invalid_value",
+		}
+	}
+	return nil
+}
+
+// newEmbeddingResponse embeds every input of a validated req.
+func (s *Server) newEmbeddingResponse(req EmbeddingRequest) EmbeddingResponse {
+	dimensions := s.opts.EmbeddingDimensions
+	if req.Dimensions != nil {
+		dimensions = *req.Dimensions
+	}
+	response := EmbeddingResponse{
+		Object: "list",
+		Data:   make([]Embedding, 0, len(req.Input)),
+		Model:  req.Model,
+	}
+	for i, input := range req.Input {
+		response.Data = append(response.Data, Embedding{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: generateEmbedding(input, dimensions),
+		})
+		response.Usage.PromptTokens += countTokens(req.Model, input)
+	}
+	response.Usage.TotalTokens = response.Usage.PromptTokens
+	return response
+}
+
+// generateEmbedding returns a unit-length vector derived from a hash of input,
+// so the same input always yields the same embedding.
+func generateEmbedding(input string, dimensions int) []float64 {
+	h := fnv.New64a()
+	h.Write([]byte(input))
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	vector := make([]float64, dimensions)
+	var norm float64
+	for i := range vector {
+		vector[i] = rng.Float64()*2 - 1
+		norm += vector[i] * vector[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
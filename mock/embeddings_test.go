@@ -0,0 +1,96 @@
+package mock
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+// embed posts an embeddings request and decodes the 200 response.
+func embed(t *testing.T, url, body string) EmbeddingResponse {
+	t.Helper()
+	resp, data := do(t, http.MethodPost, url+"/v1/embeddings", body, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var embeddings EmbeddingResponse
+	decode(t, data, &embeddings)
+	return embeddings
+}
+
+func TestEmbeddings(t *testing.T) {
+	ts := newTestServer(t, Options{EmbeddingDimensions: 8})
+
+	first := embed(t, ts.URL, `{"model":"text-embedding-3-small","input":"hello"}`)
+	if len(first.Data) != 1 || len(first.Data[0].Embedding) != 8 {
+		t.Fatalf("data = %+v, want one vector of the default 8 dimensions", first.Data)
+	}
+	var norm float64
+	for _, v := range first.Data[0].Embedding {
+		norm += v * v
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Errorf("vector has squared norm %g, want 1", norm)
+	}
+	if first.Usage.PromptTokens == 0 || first.Usage.TotalTokens != first.Usage.PromptTokens {
+		t.Errorf("usage = %+v", first.Usage)
+	}
+
+	again := embed(t, ts.URL, `{"model":"text-embedding-3-small","input":"hello"}`)
+	for i := range again.Data[0].Embedding {
+		if again.Data[0].Embedding[i] != first.Data[0].Embedding[i] {
+			t.Fatal("the same input embedded differently")
+		}
+	}
+
+	sized := embed(t, ts.URL, `{"model":"text-embedding-3-small","input":"hello","dimensions":3}`)
+	if got := len(sized.Data[0].Embedding); got != 3 {
+		t.Errorf("dimensions 3 returned %d dimensions", got)
+	}
+}
+
+func TestEmbeddingsValidation(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	tests := []struct {
+		name      string
+		body      string
+		wantParam string
+	}{
+		{"empty input", `{"model":"text-embedding-3-small","input":""}`, ""},
+		{"empty array", `{"model":"text-embedding-3-small","input":[]}`, ""},
+		{"zero dimensions", `{"model":"text-embedding-3-small","input":"hi","dimensions":0}`, "dimensions"},
+		{"too many dimensions", `{"model":"text-embedding-3-small","input":"hi","dimensions":3073}`, "dimensions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, data := do(t, http.MethodPost, ts.URL+"/v1/embeddings", tt.body, nil)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", resp.StatusCode, data)
+			}
+			var e ErrorResponse
+			decode(t, data, &e)
+			if param := e.Error.Param; (param == nil) != (tt.wantParam == "") || (param != nil && *param != tt.wantParam) {
+				t.Errorf("param = %v, want %q", param, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestEmbeddingsInputArray(t *testing.T) {
+	ts := newTestServer(t, Options{EmbeddingDimensions: 4})
+	batch := embed(t, ts.URL, `{"model":"text-embedding-3-small","input":["hello","world"]}`)
+	if len(batch.Data) != 2 {
+		t.Fatalf("got %d embeddings, want 2", len(batch.Data))
+	}
+	single := embed(t, ts.URL, `{"model":"text-embedding-3-small","input":"world"}`)
+	for i, e := range batch.Data {
+		if e.Index != i {
+			t.Errorf("embedding %d has index %d", i, e.Index)
+		}
+	}
+	for i := range single.Data[0].Embedding {
+		if batch.Data[1].Embedding[i] != single.Data[0].Embedding[i] {
+			t.Fatal("an input embeds differently inside an array")
+		}
+	}
+}
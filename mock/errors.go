@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ErrorDetail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// writeError writes an OpenAI-style error envelope with the given status. An
+// empty code is encoded as null, matching the real API.
+func writeError(w http.ResponseWriter, status int, message, errType, code string) {
+	writeParamError(w, status, message, errType, "", code)
+}
+
+// writeParamError is like writeError but also names the offending request
+// parameter. An empty param is encoded as null.
+func writeParamError(w http.ResponseWriter, status int, message, errType, param, code string) {
+	detail := ErrorDetail{
+		Message: message,
+		Type:    errType,
+	}
+	if param != "" {
+		detail.Param = &param
+	}
+	if code != "" {
+		detail.Code = &code
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: detail})
+}
+
+// writeDecodeError writes the error for a request body that could not be
+// decoded: a 413 when it exceeded MaxBodyBytes and a 400 otherwise.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+		writeBodyTooLarge(w, maxErr.Limit)
+		return
+	}
+	if decompressErr := (*DecompressError)(nil); errors.As(err, &decompressErr) {
+		writeDecompressError(w, decompressErr)
+		return
+	}
+	writeError(w, http.StatusBadRequest,
+		"We could not parse the JSON body of your request. Please make sure it is valid JSON.",
+		"invalid_request_error", "")
+}
+
+// writeBodyTooLarge writes a 413 for a request body over limit bytes.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Request body too large. The maximum size is %d bytes.", limit),
+		"invalid_request_error", "request_too_large")
+}
+
+// writeMethodNotAllowed writes a 405 listing the allowed methods in the Allow
+// header.
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed,
+		fmt.Sprintf("Invalid method for URL (%s %s)", r.Method, r.URL.Path),
+		"invalid_request_error", "")
+}
+
+// writeStatusError writes the error envelope the real API returns for status.
+func (s *Server) writeStatusError(w http.ResponseWriter, status int) {
+	switch status {
+	case http.StatusTooManyRequests:
+		s.writeRateLimited(w, time.Duration(s.opts.RetryAfter)*time.Second)
+	case http.StatusBadGateway:
+		writeError(w, status,
+			"Bad gateway.",
+			"server_error", "")
+	case http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", strconv.Itoa(s.opts.RetryAfter))
+		writeError(w, status,
+			"The engine is currently overloaded, please try again later.",
+			"server_error", "")
+	case http.StatusGatewayTimeout:
+		writeError(w, status,
+			"Request timed out.",
+			"server_error", "timeout")
+	default:
+		if status < http.StatusInternalServerError {
+			writeError(w, status, http.StatusText(status), "invalid_request_error", "")
+			return
+		}
+		writeError(w, status,
+			"The server had an error while processing your request. Sorry about that!",
+			"server_error", "")
+	}
+}
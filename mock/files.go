@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePurposes are the purposes a file may be uploaded for.
+var filePurposes = []string{"assistants", "batch", "fine-tune", "vision", "user_data", "evals"}
+
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int    `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"`
+}
+
+type storedFile struct {
+	File
+	content []byte
+}
+
+// fileStore keeps uploaded files in memory since startup or the last reset.
+type fileStore struct {
+	mu    sync.Mutex
+	files map[string]storedFile
+}
+
+func (s *fileStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.files)
+}
+
+func (s *fileStore) add(f storedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[f.ID] = f
+}
+
+func (s *fileStore) get(id string) (storedFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[id]
+	return f, ok
+}
+
+func (s *Server) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			writeBodyTooLarge(w, maxErr.Limit)
+			return
+		}
+		writeError(w, http.StatusBadRequest,
+			"Could not parse multipart form: "+err.Error(),
+			"invalid_request_error", "")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'file'.",
+			Param:   "file",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+	defer file.Close()
+	purpose := r.FormValue("purpose")
+	if purpose == "" {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'purpose'.",
+			Param:   "purpose",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+	if !slices.Contains(filePurposes, purpose) {
+		writeRequestError(w, &RequestError{
+			Message: fmt.Sprintf("Invalid value: '%s'. Supported values are: '%s'.", purpose, strings.Join(filePurposes, "', '")),
+			Param:   "purpose",
+			Code:    "invalid_value",
+		})
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest,
+			"Could not read uploaded file: "+err.Error(),
+			"invalid_request_error", "")
+		return
+	}
+
+	f := storedFile{
+		File: File{
+			ID:        "file-" + randomString(newRand(nil), 24),
+			Object:    "file",
+			Bytes:     len(content),
+			CreatedAt: s.unixTime(time.Now()),
+			Filename:  header.Filename,
+			Purpose:   purpose,
+			Status:    "processed",
+		},
+		content: content,
+	}
+	s.files.add(f)
+	requestLogger(r.Context()).Info("file uploaded", "id", f.ID, "filename", f.Filename, "purpose", f.Purpose, "bytes", f.Bytes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.File)
+}
+
+func (s *Server) handleRetrieveFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.lookupFile(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.File)
+}
+
+// handleFileContent serves the uploaded bytes as they were received.
+func (s *Server) handleFileContent(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.lookupFile(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprint(len(f.content)))
+	w.Write(f.content)
+}
+
+// lookupFile finds the file named by the request path, writing a 405 for
+// methods other than GET and HEAD and a 404 for unknown IDs.
+func (s *Server) lookupFile(w http.ResponseWriter, r *http.Request) (storedFile, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return storedFile{}, false
+	}
+	id := r.PathValue("id")
+	f, ok := s.files.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound,
+			fmt.Sprintf("No such File object: %s", id),
+			"invalid_request_error", "")
+		return storedFile{}, false
+	}
+	return f, true
+}
@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	content := "line one\nline two\n"
+
+	f := uploadFile(t, ts.URL, "assistants", content)
+	if f.Object != "file" || f.Bytes != len(content) || f.Filename != "input.jsonl" || f.Purpose != "assistants" {
+		t.Errorf("uploaded file = %+v", f)
+	}
+
+	resp, data := do(t, http.MethodGet, ts.URL+"/v1/files/"+f.ID, "", nil)
+	var got File
+	decode(t, data, &got)
+	if resp.StatusCode != http.StatusOK || got != f {
+		t.Errorf("retrieved %+v, want %+v", got, f)
+	}
+
+	resp, data = do(t, http.MethodGet, ts.URL+"/v1/files/"+f.ID+"/content", "", nil)
+	if resp.StatusCode != http.StatusOK || string(data) != content {
+		t.Errorf("content: status %d, %q, want %q", resp.StatusCode, data, content)
+	}
+
+	for _, path := range []string{"/v1/files/file-nope", "/v1/files/file-nope/content"} {
+		if resp, _ := do(t, http.MethodGet, ts.URL+path, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestUploadFileValidation(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	tests := []struct {
+		name      string
+		purpose   string
+		withFile  bool
+		wantParam string
+	}{
+		{"missing file", "batch", false, "file"},
+		{"missing purpose", "", true, "purpose"},
+		{"unknown purpose", "memes", true, "purpose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			if tt.purpose != "" {
+				form.WriteField("purpose", tt.purpose)
+			}
+			if tt.withFile {
+				part, _ := form.CreateFormFile("file", "data.jsonl")
+				part.Write([]byte("{}"))
+			}
+			form.Close()
+
+			resp, data := do(t, http.MethodPost, ts.URL+"/v1/files", body.String(), map[string]string{"Content-Type": form.FormDataContentType()})
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", resp.StatusCode, data)
+			}
+			var e ErrorResponse
+			decode(t, data, &e)
+			if e.Error.Param == nil || *e.Error.Param != tt.wantParam {
+				t.Errorf("param = %v, want %s", e.Error.Param, tt.wantParam)
+			}
+		})
+	}
+}
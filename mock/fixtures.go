@@ -0,0 +1,97 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	FixtureModeReplay = "replay"
+	FixtureModeRecord = "record"
+)
+
+// FixtureStore maps request hashes to canned chat completion responses backed
+// by a JSON file. In replay mode stored responses are served instead of
+// generated ones; in record mode every generated response is written back.
+type FixtureStore struct {
+	mu      sync.Mutex
+	path    string
+	mode    string
+	entries map[string]ChatCompletionResponse
+}
+
+// LoadFixtures reads the fixtures file at path. A missing file is only an
+// error in replay mode.
+func LoadFixtures(path, mode string) (*FixtureStore, error) {
+	store := &FixtureStore{
+		path:    path,
+		mode:    mode,
+		entries: map[string]ChatCompletionResponse{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && mode == FixtureModeRecord {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.entries); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// fixtureKey hashes the parts of req that identify a conversation.
+func fixtureKey(req ChatCompletionRequest) string {
+	sum := sha256.Sum256([]byte(toJSON(struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+	}{req.Model, req.Messages})))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns the stored response for req when replaying.
+func (s *FixtureStore) lookup(req ChatCompletionRequest) (ChatCompletionResponse, bool) {
+	if s == nil || s.mode != FixtureModeReplay {
+		return ChatCompletionResponse{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response, ok := s.entries[fixtureKey(req)]
+	return response, ok
+}
+
+// record stores response for req and rewrites the fixtures file when
+// recording.
+func (s *FixtureStore) record(req ChatCompletionRequest, response ChatCompletionResponse) error {
+	if s == nil || s.mode != FixtureModeRecord {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[fixtureKey(req)] = response
+
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".fixtures-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestFixturesRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+
+	recorder, err := LoadFixtures(path, FixtureModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := chat(t, newTestServer(t, Options{Fixtures: recorder}).URL, "")
+
+	replayer, err := LoadFixtures(path, FixtureModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, Options{Fixtures: replayer})
+	if replayed := chat(t, ts.URL, ""); replayed.ID != recorded.ID {
+		t.Errorf("replayed %s, want the recorded %s", replayed.ID, recorded.ID)
+	}
+	// Other conversations are generated as usual
+	var other ChatCompletionResponse
+	_, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+		`{"model":"gpt-4o","messages":[{"role":"user","content":"something else"}]}`, nil)
+	decode(t, data, &other)
+	if other.ID == recorded.ID {
+		t.Error("a different conversation replayed the recorded response")
+	}
+
+	if _, err := LoadFixtures(filepath.Join(t.TempDir(), "missing.json"), FixtureModeReplay); err == nil {
+		t.Error("replaying a missing fixtures file succeeded")
+	}
+}
@@ -0,0 +1,124 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRecordedBody is how much of each request body is kept in the history.
+const maxRecordedBody = 4096
+
+// RecordedRequest is one request as the mock received it. Body holds the
+// request body, decompressed and cut at maxRecordedBody bytes.
+type RecordedRequest struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Body      string    `json:"body"`
+	Truncated bool      `json:"truncated"`
+}
+
+type RequestHistoryList struct {
+	Object string            `json:"object"`
+	Data   []RecordedRequest `json:"data"`
+}
+
+// requestRing is a fixed-size ring buffer of the most recent requests.
+type requestRing struct {
+	mu      sync.Mutex
+	entries []RecordedRequest
+	next    int
+	full    bool
+}
+
+func newRequestRing(size int) *requestRing {
+	return &requestRing{entries: make([]RecordedRequest, size)}
+}
+
+func (h *requestRing) add(req RecordedRequest) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = req
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the recorded requests, oldest first.
+func (h *requestRing) list() []RecordedRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]RecordedRequest{}, h.entries[:h.next]...)
+	}
+	return append(append([]RecordedRequest{}, h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+func (h *requestRing) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.entries)
+	h.next, h.full = 0, false
+}
+
+// recordBody reads up to maxRecordedBody bytes of the body of r for the
+// history and puts them back in front of the rest, so the body is recorded
+// even when the request is rejected before a handler reads it. Compressed
+// bodies are recorded decompressed when the start of them can be.
+func recordBody(r *http.Request) (body []byte, truncated bool) {
+	raw, _ := io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(raw), r.Body), r.Body}
+
+	body, truncated = raw, len(raw) > maxRecordedBody
+	if encoding := contentEncoding(r); encoding != "" {
+		if decompressor, ok, err := newDecompressor(encoding, bytes.NewReader(raw)); ok && err == nil {
+			// A cut-off stream decompresses as far as it goes
+			decoded, _ := io.ReadAll(io.LimitReader(decompressor, maxRecordedBody+1))
+			body, truncated = decoded, truncated || len(decoded) > maxRecordedBody
+		}
+	}
+	return body[:min(len(body), maxRecordedBody)], truncated
+}
+
+// withHistory records every request in the server's history, with the body
+// as the client sent it.
+func (s *Server) withHistory(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, truncated := recordBody(r)
+		s.history.add(RecordedRequest{
+			Time:      time.Now(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     r.URL.RawQuery,
+			Body:      string(body),
+			Truncated: truncated,
+		})
+		next(w, r)
+	}
+}
+
+// handleMockRequests lists the recent requests, oldest first. The admin
+// token, or failing that the API key, is required when one is configured.
+func (s *Server) handleMockRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	if !s.checkAdminAccess(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RequestHistoryList{
+		Object: "list",
+		Data:   s.history.list(),
+	})
+}
@@ -0,0 +1,79 @@
+package mock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMockRequestsAuth(t *testing.T) {
+	testMockAuth(t, http.MethodGet, "/_mock/requests", "", []authCase{
+		{"open", Options{}, "", http.StatusOK},
+		{"without API key", Options{APIKey: "sk-test"}, "", http.StatusUnauthorized},
+		{"with wrong API key", Options{APIKey: "sk-test"}, "sk-nope", http.StatusUnauthorized},
+		{"with API key", Options{APIKey: "sk-test"}, "sk-test", http.StatusOK},
+		{"with API key instead of admin token", Options{APIKey: "sk-test", AdminToken: "admin"}, "sk-test", http.StatusUnauthorized},
+		{"with admin token", Options{APIKey: "sk-test", AdminToken: "admin"}, "admin", http.StatusOK},
+	})
+}
+
+// requestHistory returns the requests recorded by the server at url.
+func requestHistory(t *testing.T, url string) []RecordedRequest {
+	t.Helper()
+	var history RequestHistoryList
+	_, data := do(t, http.MethodGet, url+"/_mock/requests", "", nil)
+	decode(t, data, &history)
+	return history.Data
+}
+
+func TestMockRequests(t *testing.T) {
+	ts := newTestServer(t, Options{RequestHistorySize: 2})
+
+	for _, content := range []string{"one", "two", "three"} {
+		chat(t, ts.URL, `"user":"`+content+`"`)
+	}
+	do(t, http.MethodGet, ts.URL+"/v1/models/nope", "", nil)
+
+	history := requestHistory(t, ts.URL)
+	if len(history) != 2 {
+		t.Fatalf("recorded %d requests, want the last 2", len(history))
+	}
+	if last := history[1]; last.Method != http.MethodGet || last.Path != "/v1/models/nope" {
+		t.Errorf("last request = %+v, want the GET of an unknown model", last)
+	}
+	if first := history[0]; first.Path != "/v1/chat/completions" || !strings.Contains(first.Body, `"user":"three"`) {
+		t.Errorf("first request = %+v, want the third chat completion", first)
+	}
+}
+
+func TestMockRequestsBody(t *testing.T) {
+	ts := newTestServer(t, Options{APIKey: "sk-test", AdminToken: "admin"})
+	auth := map[string]string{"Authorization": "Bearer sk-test"}
+
+	// Rejected before any handler reads the body
+	do(t, http.MethodPost, ts.URL+"/v1/chat/completions", chatBody, nil)
+	do(t, http.MethodPost, ts.URL+"/v1/chat/completions", compress(t, chatBody, gzipWriter),
+		map[string]string{"Authorization": "Bearer sk-test", "Content-Encoding": "gzip"})
+	long := `{"model":"gpt-4o","messages":[{"role":"user","content":"` + strings.Repeat("a", 2*maxRecordedBody) + `"}]}`
+	do(t, http.MethodPost, ts.URL+"/v1/chat/completions", compress(t, long, gzipWriter),
+		map[string]string{"Authorization": "Bearer sk-test", "Content-Encoding": "gzip"})
+	do(t, http.MethodGet, ts.URL+"/v1/models", "", auth)
+
+	var history RequestHistoryList
+	_, data := do(t, http.MethodGet, ts.URL+"/_mock/requests", "", map[string]string{"Authorization": "Bearer admin"})
+	decode(t, data, &history)
+	if len(history.Data) != 4 {
+		t.Fatalf("recorded %d requests, want 4", len(history.Data))
+	}
+	for i, want := range []RecordedRequest{
+		{Body: chatBody},
+		{Body: chatBody},
+		{Body: long[:maxRecordedBody], Truncated: true},
+		{},
+	} {
+		if got := history.Data[i]; got.Body != want.Body || got.Truncated != want.Truncated {
+			t.Errorf("request %d recorded body %.40q (truncated %t), want %.40q (truncated %t)",
+				i, got.Body, got.Truncated, want.Body, want.Truncated)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ImageGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              *int   `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+type ImageResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+var imageSizes = map[string][2]int{
+	"256x256":   {256, 256},
+	"512x512":   {512, 512},
+	"1024x1024": {1024, 1024},
+	"1792x1024": {1792, 1024},
+	"1024x1792": {1024, 1792},
+}
+
+func (s *Server) handleImageGeneration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req ImageGenerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Prompt == "" {
+		writeRequestError(w, &RequestError{
+			Message: "Missing required parameter: 'prompt'.",
+			Param:   "prompt",
+			Code:    "missing_required_parameter",
+		})
+		return
+	}
+	if req.Size == "" {
+		req.Size = "1024x1024"
+	}
+	size, ok := imageSizes[req.Size]
+	if !ok {
+		writeRequestError(w, &RequestError{
+			Message: fmt.Sprintf("Invalid value: '%s'. Supported values are: '256x256', '512x512', '1024x1024', '1792x1024', and '1024x1792'.", req.Size),
+			Param:   "size",
+			Code:    "invalid_value",
+		})
+		return
+	}
+	if err := validateImageCount(req.Model, req.N); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	n := choiceCount(req.N)
+
+	encoded := base64.StdEncoding.EncodeToString(generateImage(req.Prompt, size[0], size[1]))
+	response := ImageResponse{
+		Created: s.unixTime(time.Now()),
+		Data:    make([]ImageData, 0, n),
+	}
+	for i := 0; i < n; i++ {
+		data := ImageData{RevisedPrompt: req.Prompt}
+		if req.ResponseFormat == "b64_json" {
+			data.B64JSON = encoded
+		} else {
+			data.URL = "data:image/png;base64," + encoded
+		}
+		response.Data = append(response.Data, data)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// maxImages returns how many images model generates per request: one for
+// dall-e-3 and ten for the others.
+func maxImages(model string) int {
+	if strings.HasPrefix(model, "dall-e-3") {
+		return 1
+	}
+	return 10
+}
+
+// validateImageCount checks that n, when set, is between 1 and the maximum
+// of model.
+func validateImageCount(model string, n *int) *RequestError {
+	switch limit := maxImages(model); {
+	case n == nil:
+		return nil
+	case *n < 1:
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid 'n': integer below minimum value. Expected a value >= 1, but got %d instead.", *n),
+			Param:   "n",
+			Code:    "integer_below_min_value",
+		}
+	case *n > limit:
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid 'n': integer above maximum value. Expected a value <= %d, but got %d instead.", limit, *n),
+			Param:   "n",
+			Code:    "integer_above_max_value",
+		}
+	}
+	return nil
+}
+
+// generateImage returns a solid-color PNG whose color is derived from a hash of
+// prompt, so the same prompt always yields the same image.
+func generateImage(prompt string, width, height int) []byte {
+	h := fnv.New32a()
+	h.Write([]byte(prompt))
+	sum := h.Sum32()
+	fill := color.RGBA{R: uint8(sum >> 16), G: uint8(sum >> 8), B: uint8(sum), A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: fill}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
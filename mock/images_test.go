@@ -0,0 +1,44 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestImageCount(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	tests := []struct {
+		model, n string
+		want     int
+		code     string
+	}{
+		{"dall-e-2", "3", 3, ""},
+		{"dall-e-2", "10", 10, ""},
+		{"dall-e-2", "0", 0, "integer_below_min_value"},
+		{"dall-e-2", "-1", 0, "integer_below_min_value"},
+		{"dall-e-2", "11", 0, "integer_above_max_value"},
+		{"dall-e-3", "1", 1, ""},
+		{"dall-e-3", "2", 0, "integer_above_max_value"},
+	}
+	for _, tt := range tests {
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/images/generations",
+			`{"model":"`+tt.model+`","prompt":"a cat","size":"256x256","n":`+tt.n+`}`, nil)
+		if tt.code != "" {
+			var e ErrorResponse
+			decode(t, data, &e)
+			if resp.StatusCode != http.StatusBadRequest || errorCode(t, data) != tt.code || e.Error.Param == nil || *e.Error.Param != "n" {
+				t.Errorf("%s n=%s: status %d: %s, want 400 %s on n", tt.model, tt.n, resp.StatusCode, data, tt.code)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s n=%s: status %d: %s", tt.model, tt.n, resp.StatusCode, data)
+			continue
+		}
+		var images ImageResponse
+		decode(t, data, &images)
+		if len(images.Data) != tt.want {
+			t.Errorf("%s n=%s: got %d images, want %d", tt.model, tt.n, len(images.Data), tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package mock
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+const maxTopLogProbs = 20
+
+type TopLogProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+type TokenLogProb struct {
+	Token       string       `json:"token"`
+	LogProb     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogProbs []TopLogProb `json:"top_logprobs"`
+}
+
+type LogProbs struct {
+	Content []TokenLogProb `json:"content"`
+	Refusal []TokenLogProb `json:"refusal"`
+}
+
+// validateLogProbs checks top_logprobs is within range and only used together
+// with logprobs.
+func validateLogProbs(req ChatCompletionRequest) *RequestError {
+	if req.TopLogProbs == nil {
+		return nil
+	}
+	if !req.LogProbs {
+		return &RequestError{
+			Message: "Invalid value for 'top_logprobs': 'logprobs' must be set to true when 'top_logprobs' is specified.",
+			Param:   "top_logprobs",
+		}
+	}
+	if *req.TopLogProbs < 0 || *req.TopLogProbs > maxTopLogProbs {
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid 'top_logprobs': integer must be between 0 and %d, got %d.", maxTopLogProbs, *req.TopLogProbs),
+			Param:   "top_logprobs",
+			Code:    "integer_out_of_range",
+		}
+	}
+	return nil
+}
+
+func topLogProbs(req ChatCompletionRequest) int {
+	if req.TopLogProbs == nil {
+		return 0
+	}
+	return *req.TopLogProbs
+}
+
+// generateLogProbs returns fake but deterministic log probabilities for
+// tokens, with top alternatives for each.
+func generateLogProbs(tokens []string, top int) *LogProbs {
+	logprobs := &LogProbs{Content: make([]TokenLogProb, 0, len(tokens))}
+	for _, token := range tokens {
+		logprob := tokenLogProb(token)
+		alternatives := make([]TopLogProb, 0, top)
+		for i := 0; i < top; i++ {
+			alternative := token
+			if i > 0 {
+				alternative = fmt.Sprintf("%s_%d", token, i)
+			}
+			alternatives = append(alternatives, TopLogProb{
+				Token:   alternative,
+				LogProb: logprob - float64(i)*1.5,
+				Bytes:   tokenBytes(alternative),
+			})
+		}
+		logprobs.Content = append(logprobs.Content, TokenLogProb{
+			Token:       token,
+			LogProb:     logprob,
+			Bytes:       tokenBytes(token),
+			TopLogProbs: alternatives,
+		})
+	}
+	return logprobs
+}
+
+// tokenLogProb derives a log probability in (-2, 0] from a hash of token.
+func tokenLogProb(token string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(token))
+	return -float64(h.Sum32()%2000) / 1000
+}
+
+func tokenBytes(token string) []int {
+	b := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		b[i] = int(token[i])
+	}
+	return b
+}
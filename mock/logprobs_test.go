@@ -0,0 +1,41 @@
+package mock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLogProbs(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	choice := chat(t, ts.URL, `"logprobs":true,"top_logprobs":2`).Choices[0]
+	if choice.LogProbs == nil || len(choice.LogProbs.Content) == 0 {
+		t.Fatalf("logprobs = %+v, want per-token entries", choice.LogProbs)
+	}
+	var tokens strings.Builder
+	for _, token := range choice.LogProbs.Content {
+		tokens.WriteString(token.Token)
+		if token.LogProb > 0 {
+			t.Errorf("token %q has logprob %g, want <= 0", token.Token, token.LogProb)
+		}
+		if len(token.TopLogProbs) != 2 || token.TopLogProbs[0].Token != token.Token {
+			t.Errorf("token %q has top_logprobs %+v, want 2 led by the token", token.Token, token.TopLogProbs)
+		}
+	}
+	if got := tokens.String(); got != choice.Message.Content.String() {
+		t.Errorf("tokens join to %q, want the content %q", got, choice.Message.Content.String())
+	}
+
+	if choice := chat(t, ts.URL, "").Choices[0]; choice.LogProbs != nil {
+		t.Errorf("logprobs = %+v without being requested", choice.LogProbs)
+	}
+
+	for _, params := range []string{`"top_logprobs":2`, `"logprobs":true,"top_logprobs":21`} {
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+			`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],`+params+`}`, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", params, resp.StatusCode, data)
+		}
+	}
+}
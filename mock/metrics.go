@@ -0,0 +1,180 @@
+package mock
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram. They follow the Prometheus client defaults, extended for long
+// streams.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type requestLabels struct {
+	path   string
+	status int
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// metricsRegistry holds the counters exposed on /metrics.
+type metricsRegistry struct {
+	mu        sync.Mutex
+	requests  map[requestLabels]uint64
+	durations map[string]*histogram
+
+	// streaming counts the streaming responses currently being written.
+	streaming atomic.Int64
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests:  make(map[requestLabels]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+func (m *metricsRegistry) observe(path string, status int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestLabels{path, status}]++
+	h, ok := m.durations[path]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(durationBuckets))}
+		m.durations[path] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (m *metricsRegistry) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.requests)
+	clear(m.durations)
+}
+
+// writeTo renders the registry in the Prometheus text exposition format.
+func (m *metricsRegistry) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].path != labels[j].path {
+			return labels[i].path < labels[j].path
+		}
+		return labels[i].status < labels[j].status
+	})
+	fmt.Fprintln(w, "# HELP mock_requests_total Total HTTP requests by path and status.")
+	fmt.Fprintln(w, "# TYPE mock_requests_total counter")
+	for _, l := range labels {
+		fmt.Fprintf(w, "mock_requests_total{path=%q,status=\"%d\"} %d\n", l.path, l.status, m.requests[l])
+	}
+
+	paths := make([]string, 0, len(m.durations))
+	for p := range m.durations {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	fmt.Fprintln(w, "# HELP mock_request_duration_seconds HTTP request duration by path.")
+	fmt.Fprintln(w, "# TYPE mock_request_duration_seconds histogram")
+	for _, p := range paths {
+		h := m.durations[p]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "mock_request_duration_seconds_bucket{path=%q,le=%q} %d\n", p, strconv.FormatFloat(le, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(w, "mock_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", p, h.count)
+		fmt.Fprintf(w, "mock_request_duration_seconds_sum{path=%q} %g\n", p, h.sum)
+		fmt.Fprintf(w, "mock_request_duration_seconds_count{path=%q} %d\n", p, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP mock_streaming_active Streaming responses currently in flight.")
+	fmt.Fprintln(w, "# TYPE mock_streaming_active gauge")
+	fmt.Fprintf(w, "mock_streaming_active %d\n", m.streaming.Load())
+}
+
+// responseWriter records the status code and byte count written through it.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// statusOrOK returns the status written so far, which is 200 when the
+// handler wrote nothing.
+func (w *responseWriter) statusOrOK() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush passes through to the underlying writer so streaming keeps working.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withMetrics records the status and duration of every request under the
+// route pattern, which keeps label cardinality bounded. Recording is deferred
+// so streams aborted with http.ErrAbortHandler are counted too.
+func (s *Server) withMetrics(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			s.metrics.observe(pattern, rw.statusOrOK(), time.Since(start))
+		}()
+		next(rw, r)
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.writeTo(w)
+}
@@ -0,0 +1,43 @@
+package mock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// scrape returns the /metrics page of the server at url.
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, data := do(t, http.MethodGet, url+"/metrics", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/metrics: status %d", resp.StatusCode)
+	}
+	return string(data)
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	chat(t, ts.URL, "")
+	chat(t, ts.URL, "")
+	do(t, http.MethodGet, ts.URL+"/v1/models/nope", "", nil)
+	do(t, http.MethodGet, ts.URL+"/healthz", "", nil)
+	do(t, http.MethodPost, ts.URL+"/_mock/config", `{}`, nil)
+	scrape(t, ts.URL)
+
+	page := scrape(t, ts.URL)
+	for _, want := range []string{
+		`mock_requests_total{path="/v1/chat/completions",status="200"} 2`,
+		`mock_requests_total{path="/v1/models/{model}",status="404"} 1`,
+		`mock_requests_total{path="/healthz",status="200"} 1`,
+		`mock_requests_total{path="/_mock/config",status="403"} 1`,
+		`mock_requests_total{path="/metrics",status="200"} 1`,
+		`mock_request_duration_seconds_count{path="/v1/chat/completions"} 2`,
+		`mock_request_duration_seconds_bucket{path="/v1/chat/completions",le="+Inf"} 2`,
+		"mock_streaming_active 0",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("metrics lack %s:\n%s", want, page)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package mock
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type loggerKey struct{}
+
+// handle registers h for pattern on mux, wrapped in the middleware shared by
+// all API endpoints.
+func (s *Server) handle(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, s.withMetrics(pattern, s.withHistory(s.withLatency(s.requireAPIKey(s.requireOrganization(s.withRateLimit(s.withPeriodicFailure(h))))))))
+}
+
+// handleAzure registers h like handle, but authenticates with the api-key
+// header Azure OpenAI clients send instead of a bearer token.
+func (s *Server) handleAzure(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, s.withMetrics(pattern, s.withHistory(s.withLatency(s.requireAzureAPIKey(s.withRateLimit(s.withPeriodicFailure(h)))))))
+}
+
+// withPeriodicFailure fails every FailEvery-th request with the next of
+// FailCodes instead of calling next.
+func (s *Server) withPeriodicFailure(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		every := s.failEvery()
+		if every == 0 {
+			next(w, r)
+			return
+		}
+		n := s.apiRequests.Add(1)
+		if n%int64(every) != 0 {
+			next(w, r)
+			return
+		}
+		codes := s.failCodes()
+		status := codes[int(n/int64(every)-1)%len(codes)]
+		requestLogger(r.Context()).Info("periodic failure", "request", n, "status", status)
+		s.writeStatusError(w, status)
+	}
+}
+
+// withLatency delays every response by Latency before calling next.
+func (s *Server) withLatency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !sleepCtx(r.Context(), s.latency()) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withAccessLog emits one structured log line per request with its status,
+// response size and duration, including requests whose handler panicked with
+// http.ErrAbortHandler to drop the connection.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			requestLogger(r.Context()).Info("request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rw.statusOrOK(),
+				"bytes", rw.bytes,
+				"duration", time.Since(start),
+			)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// withCORS adds CORS headers to every response and answers preflight requests
+// with 204 before they reach the handlers.
+func (s *Server) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", s.opts.CORSOrigin)
+		h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, DELETE, OPTIONS")
+		if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		} else {
+			h.Set("Access-Control-Allow-Headers", "Authorization, api-key, Content-Type, OpenAI-Organization, OpenAI-Project")
+		}
+		if s.opts.CORSOrigin != "*" {
+			h.Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withBodyLimit caps request bodies at MaxBodyBytes. Requests declaring a
+// larger Content-Length are rejected with 413 up front; others fail with a
+// *http.MaxBytesError once handlers read past the limit.
+func (s *Server) withBodyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > s.opts.MaxBodyBytes {
+			writeBodyTooLarge(w, s.opts.MaxBodyBytes)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequestID sets an x-request-id response header on every request, reusing
+// the client's ID when one was sent, and stores a logger tagging every line
+// with it in the request context.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("x-request-id")
+		if id == "" {
+			id = "req_" + randomString(newRand(nil), 24)
+		}
+		w.Header().Set("x-request-id", id)
+		ctx := context.WithValue(r.Context(), loggerKey{}, slog.With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestLogger returns the request-scoped logger stored by withRequestID, or
+// the default logger outside a request.
+func requestLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFailEvery(t *testing.T) {
+	ts := newTestServer(t, Options{FailEvery: 2, FailCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway}})
+	// Failures take the codes in turn
+	checkStatuses(t, ts.URL+"/v1/models",
+		http.StatusOK, http.StatusTooManyRequests,
+		http.StatusOK, http.StatusBadGateway,
+		http.StatusOK, http.StatusTooManyRequests)
+
+	// Probes do not count towards the period
+	do(t, http.MethodGet, ts.URL+"/healthz", "", nil)
+	checkStatuses(t, ts.URL+"/v1/models", http.StatusOK, http.StatusBadGateway)
+}
+
+func TestFailureRetryAfter(t *testing.T) {
+	ts := newTestServer(t, Options{FailEvery: 1, FailCodes: []int{http.StatusServiceUnavailable, http.StatusBadGateway}, RetryAfter: 7})
+	for _, tt := range []struct {
+		status int
+		want   string
+	}{
+		{http.StatusServiceUnavailable, "7"},
+		{http.StatusBadGateway, ""},
+	} {
+		resp, _ := do(t, http.MethodGet, ts.URL+"/v1/models", "", nil)
+		if resp.StatusCode != tt.status {
+			t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("Retry-After"); got != tt.want {
+			t.Errorf("%d: Retry-After = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package mock
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MockConfig summarises the settings the mock is running with, as served by
+// /_mock/config.
+type MockConfig struct {
+	Latency            string            `json:"latency"`
+	FailRate           float64           `json:"fail_rate"`
+	FailCodes          []int             `json:"fail_codes"`
+	FailEvery          int               `json:"fail_every"`
+	RetryAfter         int               `json:"retry_after"`
+	ChunkSize          int               `json:"chunk_size"`
+	StreamMode         string            `json:"stream_mode"`
+	StreamIntervalMS   int               `json:"stream_interval_ms"`
+	TTFTMS             int               `json:"ttft_ms"`
+	AuthEnabled        bool              `json:"auth_enabled"`
+	RateLimitRPM       int               `json:"rate_limit_rpm"`
+	MaxContext         int               `json:"max_context"`
+	Models             []string          `json:"models"`
+	CannedResponses    map[string]string `json:"canned_responses"`
+	RefusalTriggers    []string          `json:"refusal_triggers"`
+	ModerationTriggers map[string]string `json:"moderation_triggers"`
+	Templates          []string          `json:"templates"`
+	FixturesMode       string            `json:"fixtures_mode,omitempty"`
+}
+
+// ConfigUpdate is the body accepted by POST /_mock/config. Omitted fields keep
+// their current value.
+type ConfigUpdate struct {
+	Latency          *string  `json:"latency"`
+	FailRate         *float64 `json:"fail_rate"`
+	FailCodes        []int    `json:"fail_codes"`
+	FailEvery        *int     `json:"fail_every"`
+	ChunkSize        *int     `json:"chunk_size"`
+	StreamIntervalMS *int     `json:"stream_interval_ms"`
+	TTFTMS           *int     `json:"ttft_ms"`
+}
+
+// currentConfig snapshots the live settings.
+func (s *Server) currentConfig() MockConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := MockConfig{
+		Latency:            s.opts.Latency.String(),
+		FailRate:           s.opts.FailRate,
+		FailCodes:          s.opts.FailCodes,
+		FailEvery:          s.opts.FailEvery,
+		RetryAfter:         s.opts.RetryAfter,
+		ChunkSize:          s.opts.ChunkSize,
+		StreamMode:         s.opts.StreamMode,
+		StreamIntervalMS:   int(s.opts.StreamInterval / time.Millisecond),
+		TTFTMS:             int(s.opts.TTFT / time.Millisecond),
+		AuthEnabled:        s.opts.APIKey != "",
+		RateLimitRPM:       s.opts.RateLimitRPM,
+		MaxContext:         s.opts.MaxContext,
+		Models:             make([]string, 0, len(s.models)),
+		CannedResponses:    maps.Clone(s.opts.CannedResponses),
+		RefusalTriggers:    s.opts.RefusalTriggers,
+		ModerationTriggers: s.opts.ModerationTriggers,
+		Templates:          []string{},
+	}
+	for _, m := range s.models {
+		cfg.Models = append(cfg.Models, m.ID)
+	}
+	if s.opts.Templates != nil {
+		for _, t := range s.opts.Templates.Templates() {
+			if t.Name() != "" {
+				cfg.Templates = append(cfg.Templates, t.Name())
+			}
+		}
+		slices.Sort(cfg.Templates)
+	}
+	if s.opts.Fixtures != nil {
+		cfg.FixturesMode = s.opts.Fixtures.mode
+	}
+	return cfg
+}
+
+func (s *Server) handleMockConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		if !s.updateMockConfig(w, r) {
+			return
+		}
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPost)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.currentConfig())
+}
+
+// updateMockConfig applies the settings in the request body once the admin
+// token checks out, writing an error response and returning false otherwise.
+func (s *Server) updateMockConfig(w http.ResponseWriter, r *http.Request) bool {
+	if s.opts.AdminToken == "" {
+		writeError(w, http.StatusForbidden,
+			"Runtime configuration is disabled because no admin token is configured.",
+			"invalid_request_error", "")
+		return false
+	}
+	if !s.checkAdminToken(w, r) {
+		return false
+	}
+
+	var update ConfigUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeDecodeError(w, err)
+		return false
+	}
+
+	var latency time.Duration
+	if update.Latency != nil {
+		d, err := time.ParseDuration(*update.Latency)
+		if err != nil || d < 0 {
+			writeParamError(w, http.StatusBadRequest, "latency must be a non-negative duration such as 500ms.", "invalid_request_error", "latency", "invalid_value")
+			return false
+		}
+		latency = d
+	}
+	if update.FailRate != nil && (*update.FailRate < 0 || *update.FailRate > 1) {
+		writeParamError(w, http.StatusBadRequest, "fail_rate must be between 0 and 1.", "invalid_request_error", "fail_rate", "invalid_value")
+		return false
+	}
+	for _, code := range update.FailCodes {
+		if code < 400 || code > 599 {
+			writeParamError(w, http.StatusBadRequest, "fail_codes must be 4xx or 5xx status codes.", "invalid_request_error", "fail_codes", "invalid_value")
+			return false
+		}
+	}
+	if update.FailEvery != nil && *update.FailEvery < 0 {
+		writeParamError(w, http.StatusBadRequest, "fail_every must not be negative.", "invalid_request_error", "fail_every", "invalid_value")
+		return false
+	}
+	if update.ChunkSize != nil && *update.ChunkSize < 1 {
+		writeParamError(w, http.StatusBadRequest, "chunk_size must be at least 1.", "invalid_request_error", "chunk_size", "invalid_value")
+		return false
+	}
+	if update.StreamIntervalMS != nil && *update.StreamIntervalMS < 0 {
+		writeParamError(w, http.StatusBadRequest, "stream_interval_ms must not be negative.", "invalid_request_error", "stream_interval_ms", "invalid_value")
+		return false
+	}
+	if update.TTFTMS != nil && *update.TTFTMS < 0 {
+		writeParamError(w, http.StatusBadRequest, "ttft_ms must not be negative.", "invalid_request_error", "ttft_ms", "invalid_value")
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if update.Latency != nil {
+		s.opts.Latency = latency
+	}
+	if update.FailRate != nil {
+		s.opts.FailRate = *update.FailRate
+	}
+	if len(update.FailCodes) > 0 {
+		s.opts.FailCodes = update.FailCodes
+	}
+	if update.FailEvery != nil {
+		// Restart the count so the next failure is a full period away
+		s.opts.FailEvery = *update.FailEvery
+		s.apiRequests.Store(0)
+	}
+	if update.ChunkSize != nil {
+		s.opts.ChunkSize = *update.ChunkSize
+	}
+	if update.StreamIntervalMS != nil {
+		s.opts.StreamInterval = time.Duration(*update.StreamIntervalMS) * time.Millisecond
+	}
+	if update.TTFTMS != nil {
+		s.opts.TTFT = time.Duration(*update.TTFTMS) * time.Millisecond
+	}
+	requestLogger(r.Context()).Info("mock config updated", "update", update)
+	return true
+}
+
+// checkAdminToken reports whether r carries AdminToken as its bearer token,
+// writing a 401 when it does not.
+func (s *Server) checkAdminToken(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.AdminToken)) != 1 {
+		writeError(w, http.StatusUnauthorized,
+			"Invalid admin token.",
+			"invalid_request_error", "invalid_api_key")
+		return false
+	}
+	return true
+}
+
+// checkAdminAccess guards the /_mock endpoints that expose or clear state. It
+// requires AdminToken when one is configured and falls back to APIKey
+// otherwise, so a server protected by an API key never leaves them open.
+func (s *Server) checkAdminAccess(w http.ResponseWriter, r *http.Request) bool {
+	if s.opts.AdminToken != "" {
+		return s.checkAdminToken(w, r)
+	}
+	if s.opts.APIKey == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		writeError(w, http.StatusUnauthorized,
+			"You didn't provide an API key. You need to provide your API key in an Authorization header using Bearer auth (i.e. Authorization: Bearer YOUR_KEY).",
+			"invalid_request_error", "invalid_api_key")
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.APIKey)) != 1 {
+		writeError(w, http.StatusUnauthorized,
+			"Incorrect API key provided: "+maskAPIKey(token)+".",
+			"invalid_request_error", "invalid_api_key")
+		return false
+	}
+	return true
+}
+
+// handleMockReset clears the in-memory state that builds up across requests:
+// rate limit buckets and windows, metrics, the prompt cache, batches, uploaded
+// files, the request history and the periodic failure count. The admin token,
+// or failing that the API key, is required when one is configured.
+func (s *Server) handleMockReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if !s.checkAdminAccess(w, r) {
+		return
+	}
+	s.usageWindow.reset()
+	s.requestLimiter.reset()
+	s.userLimiter.reset()
+	s.metrics.reset()
+	s.promptCache.reset()
+	s.batches.reset()
+	s.files.reset()
+	s.history.reset()
+	s.apiRequests.Store(0)
+	requestLogger(r.Context()).Info("mock state reset")
+	w.WriteHeader(http.StatusNoContent)
+}
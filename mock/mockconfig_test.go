@@ -0,0 +1,141 @@
+package mock
+
+import (
+	"net/http"
+	"testing"
+)
+
+// authCase is a /_mock request made with token against a server configured
+// by opts.
+type authCase struct {
+	name       string
+	opts       Options
+	token      string
+	wantStatus int
+}
+
+// testMockAuth sends method path with body for every case on a fresh server
+// and checks the status.
+func testMockAuth(t *testing.T, method, path, body string, cases []authCase) {
+	t.Helper()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, tt.opts)
+			header := map[string]string{}
+			if tt.token != "" {
+				header["Authorization"] = "Bearer " + tt.token
+			}
+			resp, data := do(t, method, ts.URL+path, body, header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", resp.StatusCode, tt.wantStatus, data)
+			}
+		})
+	}
+}
+
+func TestMockConfigAuth(t *testing.T) {
+	testMockAuth(t, http.MethodGet, "/_mock/config", "", []authCase{
+		{"read without admin token", Options{}, "", http.StatusOK},
+	})
+	testMockAuth(t, http.MethodPost, "/_mock/config", `{}`, []authCase{
+		{"update without admin token configured", Options{}, "", http.StatusForbidden},
+		{"update with API key only", Options{APIKey: "sk-test"}, "sk-test", http.StatusForbidden},
+		{"update without token", Options{AdminToken: "admin"}, "", http.StatusUnauthorized},
+		{"update with wrong token", Options{AdminToken: "admin"}, "nope", http.StatusUnauthorized},
+		{"update with admin token", Options{AdminToken: "admin"}, "admin", http.StatusOK},
+	})
+}
+
+func TestMockConfigUpdate(t *testing.T) {
+	ts := newTestServer(t, Options{AdminToken: "admin"})
+	admin := map[string]string{"Authorization": "Bearer admin"}
+
+	for _, body := range []string{
+		`{"latency":"soon"}`,
+		`{"fail_rate":1.5}`,
+		`{"fail_codes":[200]}`,
+		`{"chunk_size":0}`,
+		`{"stream_interval_ms":-1}`,
+		`{"ttft_ms":-1}`,
+		`{"fail_every":-1}`,
+	} {
+		resp, data := do(t, http.MethodPost, ts.URL+"/_mock/config", body, admin)
+		if resp.StatusCode != http.StatusBadRequest || errorCode(t, data) != "invalid_value" {
+			t.Errorf("%s: status %d: %s", body, resp.StatusCode, data)
+		}
+	}
+
+	resp, data := do(t, http.MethodPost, ts.URL+"/_mock/config",
+		`{"latency":"5ms","fail_rate":0.25,"chunk_size":4,"stream_interval_ms":7,"ttft_ms":9}`, admin)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var cfg MockConfig
+	decode(t, data, &cfg)
+	if cfg.Latency != "5ms" || cfg.FailRate != 0.25 || cfg.ChunkSize != 4 || cfg.StreamIntervalMS != 7 || cfg.TTFTMS != 9 {
+		t.Errorf("config = %+v, want the update applied", cfg)
+	}
+}
+
+func TestMockConfigFailEvery(t *testing.T) {
+	ts := newTestServer(t, Options{AdminToken: "admin"})
+	admin := map[string]string{"Authorization": "Bearer admin"}
+
+	resp, data := do(t, http.MethodPost, ts.URL+"/_mock/config", `{"fail_every":2,"fail_codes":[503]}`, admin)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var cfg MockConfig
+	decode(t, data, &cfg)
+	if cfg.FailEvery != 2 {
+		t.Errorf("fail_every = %d, want 2", cfg.FailEvery)
+	}
+	checkStatuses(t, ts.URL+"/v1/models", http.StatusOK, http.StatusServiceUnavailable, http.StatusOK, http.StatusServiceUnavailable)
+
+	do(t, http.MethodPost, ts.URL+"/_mock/config", `{"fail_every":0}`, admin)
+	checkStatuses(t, ts.URL+"/v1/models", http.StatusOK, http.StatusOK)
+}
+
+// checkStatuses gets url once per wanted status and checks each response.
+func checkStatuses(t *testing.T, url string, want ...int) {
+	t.Helper()
+	got := make([]int, len(want))
+	for i := range want {
+		resp, _ := do(t, http.MethodGet, url, "", nil)
+		got[i] = resp.StatusCode
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMockResetAuth(t *testing.T) {
+	testMockAuth(t, http.MethodPost, "/_mock/reset", "", []authCase{
+		{"open", Options{}, "", http.StatusNoContent},
+		{"without API key", Options{APIKey: "sk-test"}, "", http.StatusUnauthorized},
+		{"with wrong API key", Options{APIKey: "sk-test"}, "sk-nope", http.StatusUnauthorized},
+		{"with API key", Options{APIKey: "sk-test"}, "sk-test", http.StatusNoContent},
+		{"with API key instead of admin token", Options{APIKey: "sk-test", AdminToken: "admin"}, "sk-test", http.StatusUnauthorized},
+		{"with admin token", Options{APIKey: "sk-test", AdminToken: "admin"}, "admin", http.StatusNoContent},
+	})
+}
+
+func TestMockReset(t *testing.T) {
+	ts := newTestServer(t, Options{RateLimitRPM: 1})
+
+	checkStatuses(t, ts.URL+"/v1/models", http.StatusOK, http.StatusTooManyRequests)
+	if resp, _ := do(t, http.MethodPost, ts.URL+"/_mock/reset", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("reset: status %d, want 204", resp.StatusCode)
+	}
+	// The bucket is full again
+	checkStatuses(t, ts.URL+"/v1/models", http.StatusOK)
+
+	var history RequestHistoryList
+	_, data := do(t, http.MethodGet, ts.URL+"/_mock/requests", "", nil)
+	decode(t, data, &history)
+	if len(history.Data) != 1 {
+		t.Errorf("history has %d requests after reset, want 1", len(history.Data))
+	}
+}
@@ -0,0 +1,97 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+// AvailableModels is the list of models served by /v1/models unless
+// Options.Models restricts it.
+var AvailableModels = []Model{
+	{ID: "gpt-4o", Object: "model", Created: 1715367049, OwnedBy: "system"},
+	{ID: "gpt-4o-mini", Object: "model", Created: 1721172741, OwnedBy: "system"},
+	{ID: "gpt-4-turbo", Object: "model", Created: 1712361441, OwnedBy: "system"},
+	{ID: "gpt-4", Object: "model", Created: 1687882411, OwnedBy: "openai"},
+	{ID: "gpt-3.5-turbo", Object: "model", Created: 1677610602, OwnedBy: "openai"},
+}
+
+// availableModels returns the models served by /v1/models: AvailableModels,
+// or the allowed models when the server is restricted to some.
+func availableModels(allowed []string) []Model {
+	if len(allowed) == 0 {
+		return AvailableModels
+	}
+	created := time.Now().Unix()
+	models := make([]Model, 0, len(allowed))
+	for _, id := range allowed {
+		models = append(models, Model{ID: id, Object: "model", Created: created, OwnedBy: "system"})
+	}
+	return models
+}
+
+// modelAllowed reports whether requests may use model.
+func (s *Server) modelAllowed(model string) bool {
+	return len(s.opts.Models) == 0 || slices.Contains(s.opts.Models, model)
+}
+
+func writeModelNotFound(w http.ResponseWriter, model string) {
+	writeParamError(w, http.StatusNotFound,
+		fmt.Sprintf("The model `%s` does not exist or you do not have access to it.", model),
+		"invalid_request_error", "model", "model_not_found")
+}
+
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	response := ModelList{
+		Object: "list",
+		Data:   s.models,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleRetrieveModel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	id := r.PathValue("model")
+	model, ok := s.findModel(id)
+	if !ok {
+		writeModelNotFound(w, id)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model)
+}
+
+func (s *Server) findModel(id string) (Model, bool) {
+	for _, m := range s.models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
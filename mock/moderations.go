@@ -0,0 +1,97 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type ModerationRequest struct {
+	Model string        `json:"model"`
+	Input StringOrSlice `json:"input"`
+}
+
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+var moderationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/instructions",
+	"self-harm/intent",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+func (s *Server) handleModerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.Input) == 0 {
+		writeError(w, http.StatusBadRequest,
+			"'$.input' is invalid. The input must be a non-empty string or array of strings.",
+			"invalid_request_error", "")
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "omni-moderation-latest"
+	}
+	response := ModerationResponse{
+		ID:      "modr-" + randomString(newRand(nil), 24),
+		Model:   model,
+		Results: make([]ModerationResult, 0, len(req.Input)),
+	}
+	for _, input := range req.Input {
+		response.Results = append(response.Results, s.moderate(input))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// moderate flags input in the category of every trigger it contains.
+func (s *Server) moderate(input string) ModerationResult {
+	result := ModerationResult{
+		Categories:     make(map[string]bool, len(moderationCategories)),
+		CategoryScores: make(map[string]float64, len(moderationCategories)),
+	}
+	for _, category := range moderationCategories {
+		result.Categories[category] = false
+		result.CategoryScores[category] = 0.0001
+	}
+
+	lower := strings.ToLower(input)
+	for term, category := range s.opts.ModerationTriggers {
+		if strings.Contains(lower, term) {
+			result.Flagged = true
+			result.Categories[category] = true
+			result.CategoryScores[category] = 0.98
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package mock
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+)
+
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type prefixKey struct {
+	model string
+	hash  uint64
+}
+
+// prefixCache is an LRU of message prefixes from recent requests, used to
+// simulate prompt caching.
+type prefixCache struct {
+	mu        sync.Mutex
+	size      int
+	minTokens int
+	order     *list.List
+	entries   map[prefixKey]*list.Element
+}
+
+func newPrefixCache(size, minTokens int) *prefixCache {
+	return &prefixCache{
+		size:      size,
+		minTokens: minTokens,
+		order:     list.New(),
+		entries:   map[prefixKey]*list.Element{},
+	}
+}
+
+func (c *prefixCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// cachedTokens returns the prompt tokens in the longest prefix of messages
+// already seen for model, or zero when it is shorter than minTokens. Every
+// prefix of messages is remembered for later requests.
+func (c *prefixCache) cachedTokens(model string, messages []Message) int {
+	// Tokenizing is slow, so it happens before taking the lock
+	keys := make([]prefixKey, len(messages))
+	tokens := make([]int, len(messages))
+	h := fnv.New64a()
+	total := 0
+	for i, m := range messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content.String()))
+		h.Write([]byte{0})
+		total += countTokens(model, m.Content.String())
+		keys[i], tokens[i] = prefixKey{model, h.Sum64()}, total
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := 0
+	for i, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			c.order.MoveToFront(e)
+			cached = tokens[i]
+			continue
+		}
+		c.entries[key] = c.order.PushFront(key)
+		if c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(prefixKey))
+		}
+	}
+	if cached < c.minTokens {
+		return 0
+	}
+	return cached
+}
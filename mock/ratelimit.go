@@ -0,0 +1,182 @@
+package mock
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow tracks requests and tokens consumed in the current
+// one-minute window, used to report plausible x-ratelimit-* values.
+type rateLimitWindow struct {
+	mu       sync.Mutex
+	start    time.Time
+	requests int
+	tokens   int
+
+	// requestLimit and tokenLimit are the per-minute budgets reported.
+	requestLimit int
+	tokenLimit   int
+}
+
+func (rw *rateLimitWindow) reset() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.start = time.Time{}
+	rw.requests = 0
+	rw.tokens = 0
+}
+
+// consume records a request using tokens and returns the remaining request and
+// token budgets along with the time until the window resets.
+func (rw *rateLimitWindow) consume(tokens int) (int, int, time.Duration) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rw.start) >= time.Minute {
+		rw.start = now
+		rw.requests = 0
+		rw.tokens = 0
+	}
+	rw.requests++
+	rw.tokens += tokens
+
+	reset := rw.start.Add(time.Minute).Sub(now).Round(time.Millisecond)
+	return max(rw.requestLimit-rw.requests, 0), max(rw.tokenLimit-rw.tokens, 0), reset
+}
+
+// tokenBucket is a single client's bucket in a keyedLimiter.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// keyedLimiter is a token-bucket rate limiter with one bucket per key.
+type keyedLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+	capacity float64
+	perSec   float64
+}
+
+func newKeyedLimiter(capacity, perMinute int) *keyedLimiter {
+	return &keyedLimiter{
+		buckets:  map[string]*tokenBucket{},
+		capacity: float64(capacity),
+		perSec:   float64(perMinute) / 60,
+	}
+}
+
+// reset refills every bucket by forgetting them. It is a no-op on a nil
+// limiter.
+func (l *keyedLimiter) reset() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.buckets)
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it returns
+// false and the time until the next token is available.
+func (l *keyedLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.capacity, b.tokens+now.Sub(b.last).Seconds()*l.perSec)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSec * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// allowUser applies BlockedUsers and the per-user limit to the user field of a
+// request. Requests without a user are always allowed.
+func (s *Server) allowUser(user string) (bool, time.Duration) {
+	if user == "" {
+		return true, 0
+	}
+	if slices.Contains(s.opts.BlockedUsers, user) {
+		return false, time.Duration(s.opts.RetryAfter) * time.Second
+	}
+	if s.userLimiter == nil {
+		return true, 0
+	}
+	return s.userLimiter.allow(user)
+}
+
+// withRateLimit rejects requests with a 429 once the caller has exhausted its
+// bucket.
+func (s *Server) withRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.requestLimiter == nil {
+			next(w, r)
+			return
+		}
+		if ok, retryAfter := s.requestLimiter.allow(rateLimitKey(r)); !ok {
+			s.writeRateLimited(w, retryAfter)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitKey identifies the caller of r by its bearer token or Azure
+// api-key, or by its remote IP when it sends neither, so anonymous clients do
+// not share one bucket.
+func rateLimitKey(r *http.Request) string {
+	if key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); key != "" {
+		return "key:" + key
+	}
+	if key := r.Header.Get("api-key"); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// recordUsage counts a request using tokens against the usage window and sets
+// the resulting x-ratelimit-* headers on h.
+func (s *Server) recordUsage(h http.Header, tokens int) {
+	remainingRequests, remainingTokens, reset := s.usageWindow.consume(tokens)
+	s.setRateLimitHeaders(h, remainingRequests, remainingTokens, reset)
+}
+
+// setRateLimitHeaders sets the x-ratelimit-* headers the real API returns.
+func (s *Server) setRateLimitHeaders(h http.Header, remainingRequests, remainingTokens int, reset time.Duration) {
+	h.Set("x-ratelimit-limit-requests", strconv.Itoa(s.opts.RateLimitRequests))
+	h.Set("x-ratelimit-limit-tokens", strconv.Itoa(s.opts.RateLimitTokens))
+	h.Set("x-ratelimit-remaining-requests", strconv.Itoa(remainingRequests))
+	h.Set("x-ratelimit-remaining-tokens", strconv.Itoa(remainingTokens))
+	h.Set("x-ratelimit-reset-requests", reset.String())
+	h.Set("x-ratelimit-reset-tokens", reset.String())
+}
+
+// writeRateLimited writes a 429 with Retry-After and exhausted rate limit
+// headers so clients can exercise their backoff.
+func (s *Server) writeRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	s.setRateLimitHeaders(w.Header(), 0, 0, retryAfter)
+	writeError(w, http.StatusTooManyRequests,
+		"Rate limit reached for requests. Please try again later.",
+		"requests", "rate_limit_exceeded")
+}
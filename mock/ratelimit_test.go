@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	ts := newTestServer(t, Options{RateLimitRPM: 1})
+	auth := func(key string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + key}
+	}
+
+	if resp, data := do(t, http.MethodGet, ts.URL+"/v1/models", "", auth("sk-a")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first request: status %d: %s", resp.StatusCode, data)
+	}
+
+	resp, data := do(t, http.MethodGet, ts.URL+"/v1/models", "", auth("sk-a"))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", resp.StatusCode)
+	}
+	if code := errorCode(t, data); code != "rate_limit_exceeded" {
+		t.Errorf("code = %q, want rate_limit_exceeded", code)
+	}
+	// One token a minute means waiting most of a minute for the next one
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q, want 1 to 60 seconds", resp.Header.Get("Retry-After"))
+	}
+	if got := resp.Header.Get("x-ratelimit-remaining-requests"); got != "0" {
+		t.Errorf("x-ratelimit-remaining-requests = %q, want 0", got)
+	}
+
+	// Every key has its own bucket
+	if resp, _ := do(t, http.MethodGet, ts.URL+"/v1/models", "", auth("sk-b")); resp.StatusCode != http.StatusOK {
+		t.Errorf("other key: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRateLimitAnonymous(t *testing.T) {
+	s := NewServer(Options{RateLimitRPM: 1})
+	get := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if got := get("10.0.0.1:1234"); got != http.StatusOK {
+		t.Fatalf("first request: status %d, want 200", got)
+	}
+	// The port changes with every connection, the client does not
+	if got := get("10.0.0.1:5678"); got != http.StatusTooManyRequests {
+		t.Errorf("same IP: status %d, want 429", got)
+	}
+	if got := get("10.0.0.2:1234"); got != http.StatusOK {
+		t.Errorf("other IP: status %d, want 200", got)
+	}
+}
+
+func TestBlockedUser(t *testing.T) {
+	ts := newTestServer(t, Options{BlockedUsers: []string{"mallory"}, RetryAfter: 7})
+	body := func(user string) string {
+		return `{"model":"gpt-4o","user":"` + user + `","messages":[{"role":"user","content":"hi"}]}`
+	}
+
+	resp, _ := do(t, http.MethodPost, ts.URL+"/v1/chat/completions", body("mallory"), nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("blocked user: status %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After = %q, want 7", got)
+	}
+	if resp, _ := do(t, http.MethodPost, ts.URL+"/v1/chat/completions", body("alice"), nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("other user: status %d, want 200", resp.StatusCode)
+	}
+}
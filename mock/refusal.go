@@ -0,0 +1,27 @@
+package mock
+
+import "strings"
+
+// RefusalMessage is the refusal returned by the refuse model or when a refusal
+// trigger matches.
+const RefusalMessage = "I'm sorry, but I can't assist with that request."
+
+// generateRefusal reports whether the assistant should refuse req and, if so,
+// returns the refusal text.
+func (s *Server) generateRefusal(req ChatCompletionRequest) (string, bool) {
+	if req.Model == RefuseModel {
+		return RefusalMessage, true
+	}
+	for _, m := range req.Messages {
+		if m.Role != "user" {
+			continue
+		}
+		content := strings.ToLower(m.Content.String())
+		for _, trigger := range s.opts.RefusalTriggers {
+			if strings.Contains(content, strings.ToLower(trigger)) {
+				return RefusalMessage, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,300 @@
+package mock
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ResponseInput is the input of a Responses API request, which the API
+// accepts either as a plain string or as an array of messages.
+type ResponseInput []Message
+
+func (in *ResponseInput) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*in = ResponseInput{{Role: "user", Content: TextContent(s)}}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]Message)(in))
+}
+
+type ResponsesRequest struct {
+	Model           string        `json:"model"`
+	Input           ResponseInput `json:"input"`
+	Instructions    string        `json:"instructions,omitempty"`
+	Stream          bool          `json:"stream"`
+	MaxOutputTokens *int          `json:"max_output_tokens,omitempty"`
+}
+
+// messages returns the conversation, with the instructions as a leading
+// system message.
+func (req ResponsesRequest) messages() []Message {
+	if req.Instructions == "" {
+		return req.Input
+	}
+	return append([]Message{{Role: "system", Content: TextContent(req.Instructions)}}, req.Input...)
+}
+
+type ResponseOutputContent struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+type ResponseOutputItem struct {
+	Type    string                  `json:"type"`
+	ID      string                  `json:"id"`
+	Status  string                  `json:"status"`
+	Role    string                  `json:"role"`
+	Content []ResponseOutputContent `json:"content"`
+}
+
+type ResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type Response struct {
+	ID                string               `json:"id"`
+	Object            string               `json:"object"`
+	CreatedAt         int64                `json:"created_at"`
+	Status            string               `json:"status"`
+	IncompleteDetails *IncompleteDetails   `json:"incomplete_details"`
+	Model             string               `json:"model"`
+	Output            []ResponseOutputItem `json:"output"`
+	Usage             *ResponseUsage       `json:"usage"`
+}
+
+// The Responses API streams typed events, each named by its type field and
+// numbered by sequence_number in the order they are sent.
+
+// ResponseEvent reports a change in the state of the whole response.
+type ResponseEvent struct {
+	Type           string   `json:"type"`
+	SequenceNumber int      `json:"sequence_number"`
+	Response       Response `json:"response"`
+}
+
+// ResponseItemEvent reports an output item being added or finished.
+type ResponseItemEvent struct {
+	Type           string             `json:"type"`
+	SequenceNumber int                `json:"sequence_number"`
+	OutputIndex    int                `json:"output_index"`
+	Item           ResponseOutputItem `json:"item"`
+}
+
+// ResponsePartEvent reports a content part being added or finished.
+type ResponsePartEvent struct {
+	Type           string                `json:"type"`
+	SequenceNumber int                   `json:"sequence_number"`
+	ItemID         string                `json:"item_id"`
+	OutputIndex    int                   `json:"output_index"`
+	ContentIndex   int                   `json:"content_index"`
+	Part           ResponseOutputContent `json:"part"`
+}
+
+// ResponseTextDelta carries one piece of output text.
+type ResponseTextDelta struct {
+	Type           string `json:"type"`
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	ContentIndex   int    `json:"content_index"`
+	Delta          string `json:"delta"`
+}
+
+// ResponseTextDone carries the full output text once it is complete.
+type ResponseTextDone struct {
+	Type           string `json:"type"`
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	ContentIndex   int    `json:"content_index"`
+	Text           string `json:"text"`
+}
+
+func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req ResponsesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if !s.modelAllowed(req.Model) {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	if len(req.Input) == 0 {
+		writeParamError(w, http.StatusBadRequest,
+			"Missing required parameter: 'input'.",
+			"invalid_request_error", "input", "missing_required_parameter")
+		return
+	}
+
+	log := requestLogger(r.Context()).With("model", req.Model)
+	resp := s.newResponse(log, req, r.Header.Get(TemplateHeader), padTokens(r))
+	text := resp.Output[0].Content[0].Text
+
+	if !sleepCtx(r.Context(), s.modelLatency(req.Model)) {
+		return
+	}
+
+	log.Info("response request", "stream", req.Stream, "input_messages", len(req.Input))
+
+	if !req.Stream {
+		if !sleepCtx(r.Context(), requestDelay(r.Context())) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	release, ok := s.acquireStream()
+	if !ok {
+		s.writeStreamLimited(w)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	stream := s.newChunkStream(w, r, log, resp.ID, resp.CreatedAt)
+	if !stream.wait(requestDelay(r.Context()) + s.ttft(r)) {
+		return
+	}
+
+	s.streamResponse(r, stream, resp, s.streamPieces(r, text))
+}
+
+// newResponse generates the complete response to req, rendering template and
+// padding the text with padTokens filler words when set.
+func (s *Server) newResponse(log *slog.Logger, req ResponsesRequest, template string, padTokens int) Response {
+	messages := req.messages()
+	text, finishReason := applyMaxTokens(req.Model, s.generateResponse(log, ChatCompletionRequest{
+		Model:     req.Model,
+		Messages:  messages,
+		Template:  template,
+		PadTokens: padTokens,
+	}), req.MaxOutputTokens)
+	rng := newRand(nil)
+	usage := calculateUsage(req.Model, messages, text)
+	resp := Response{
+		ID:        "resp_" + randomString(rng, 24),
+		Object:    "response",
+		CreatedAt: s.unixTime(time.Now()),
+		Status:    "completed",
+		Model:     req.Model,
+		Output: []ResponseOutputItem{{
+			Type:    "message",
+			ID:      "msg_" + randomString(rng, 24),
+			Status:  "completed",
+			Role:    "assistant",
+			Content: []ResponseOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+		}},
+		Usage: &ResponseUsage{
+			InputTokens:  usage.PromptTokens,
+			OutputTokens: usage.CompletionTokens,
+			TotalTokens:  usage.TotalTokens,
+		},
+	}
+	if finishReason == "length" {
+		resp.Status = "incomplete"
+		resp.IncompleteDetails = &IncompleteDetails{Reason: "max_output_tokens"}
+		resp.Output[0].Status = "incomplete"
+	}
+	return resp
+}
+
+// streamResponse writes the events of a streamed response: the response is
+// created, its single message item and text part are opened, the text is
+// streamed in pieces, and everything is closed again in reverse order.
+func (s *Server) streamResponse(r *http.Request, stream *chunkStream, resp Response, pieces []string) {
+	// The response starts out empty, without output or usage
+	pending := resp
+	pending.Status = "in_progress"
+	pending.IncompleteDetails = nil
+	pending.Output = []ResponseOutputItem{}
+	pending.Usage = nil
+
+	item := resp.Output[0]
+	part := item.Content[0]
+	openItem := item
+	openItem.Status = "in_progress"
+	openItem.Content = []ResponseOutputContent{}
+	openPart := part
+	openPart.Text = ""
+
+	for _, name := range []string{"response.created", "response.in_progress"} {
+		stream.writeNamed(name, ResponseEvent{Type: name, SequenceNumber: stream.chunks, Response: pending})
+	}
+	stream.writeNamed("response.output_item.added", ResponseItemEvent{
+		Type:           "response.output_item.added",
+		SequenceNumber: stream.chunks,
+		Item:           openItem,
+	})
+	stream.writeNamed("response.content_part.added", ResponsePartEvent{
+		Type:           "response.content_part.added",
+		SequenceNumber: stream.chunks,
+		ItemID:         item.ID,
+		Part:           openPart,
+	})
+
+	for i, piece := range pieces {
+		stream.writeNamed("response.output_text.delta", ResponseTextDelta{
+			Type:           "response.output_text.delta",
+			SequenceNumber: stream.chunks,
+			ItemID:         item.ID,
+			Delta:          piece,
+		})
+		if !sleepCtx(r.Context(), s.chunkDelay(r, piece)) {
+			stream.log.Info("stream cancelled", "id", resp.ID, "sent", i+1, "total", len(pieces))
+			return
+		}
+	}
+
+	stream.writeNamed("response.output_text.done", ResponseTextDone{
+		Type:           "response.output_text.done",
+		SequenceNumber: stream.chunks,
+		ItemID:         item.ID,
+		Text:           part.Text,
+	})
+	stream.writeNamed("response.content_part.done", ResponsePartEvent{
+		Type:           "response.content_part.done",
+		SequenceNumber: stream.chunks,
+		ItemID:         item.ID,
+		Part:           part,
+	})
+	stream.writeNamed("response.output_item.done", ResponseItemEvent{
+		Type:           "response.output_item.done",
+		SequenceNumber: stream.chunks,
+		Item:           item,
+	})
+
+	name := "response.completed"
+	if resp.Status == "incomplete" {
+		name = "response.incomplete"
+	}
+	stream.writeNamed(name, ResponseEvent{Type: name, SequenceNumber: stream.chunks, Response: resp})
+	stream.complete()
+}
@@ -0,0 +1,117 @@
+package mock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// respond posts a Responses API request and decodes the 200 response.
+func respond(t *testing.T, url, body string) Response {
+	t.Helper()
+	resp, data := do(t, http.MethodPost, url+"/v1/responses", body, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, data)
+	}
+	var r Response
+	decode(t, data, &r)
+	return r
+}
+
+func TestResponses(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	r := respond(t, ts.URL, `{"model":"gpt-4o","input":"hi"}`)
+	if r.Object != "response" || r.Status != "completed" || r.IncompleteDetails != nil {
+		t.Errorf("response = %+v, want a completed response", r)
+	}
+	if len(r.Output) != 1 || len(r.Output[0].Content) != 1 || r.Output[0].Content[0].Text != DefaultResponse {
+		t.Fatalf("output = %+v, want one message with the default reply", r.Output)
+	}
+	if r.Usage == nil || r.Usage.OutputTokens == 0 || r.Usage.TotalTokens != r.Usage.InputTokens+r.Usage.OutputTokens {
+		t.Errorf("usage = %+v", r.Usage)
+	}
+
+	// Input also comes as messages, with instructions counted as a system message
+	withInstructions := respond(t, ts.URL, `{"model":"gpt-4o","instructions":"be brief","input":[{"role":"user","content":"hi"}]}`)
+	if withInstructions.Usage.InputTokens <= r.Usage.InputTokens {
+		t.Errorf("instructions added no input tokens: %d vs %d", withInstructions.Usage.InputTokens, r.Usage.InputTokens)
+	}
+
+	r = respond(t, ts.URL, `{"model":"gpt-4o","input":"hi","max_output_tokens":2}`)
+	if r.Status != "incomplete" || r.IncompleteDetails == nil || r.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("truncated response = %+v, want incomplete for max_output_tokens", r)
+	}
+	if r.Usage.OutputTokens != 2 {
+		t.Errorf("output_tokens = %d, want 2", r.Usage.OutputTokens)
+	}
+
+	resp, data := do(t, http.MethodPost, ts.URL+"/v1/responses", `{"model":"gpt-4o"}`, nil)
+	if resp.StatusCode != http.StatusBadRequest || errorCode(t, data) != "missing_required_parameter" {
+		t.Errorf("missing input: status %d: %s", resp.StatusCode, data)
+	}
+}
+
+// responseEvent is the part of a Responses API stream event the tests check.
+type responseEvent struct {
+	Type           string   `json:"type"`
+	SequenceNumber int      `json:"sequence_number"`
+	Delta          string   `json:"delta"`
+	Text           string   `json:"text"`
+	Response       Response `json:"response"`
+}
+
+func TestResponsesStream(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	_, data := do(t, http.MethodPost, ts.URL+"/v1/responses", `{"model":"gpt-4o","input":"hi","stream":true}`, nil)
+
+	var events []responseEvent
+	name := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			name = v
+			continue
+		}
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event responseEvent
+		decode(t, []byte(payload), &event)
+		if event.Type != name {
+			t.Errorf("event %q carries type %q", name, event.Type)
+		}
+		events = append(events, event)
+	}
+
+	var types []string
+	text := ""
+	for i, event := range events {
+		if event.SequenceNumber != i {
+			t.Errorf("event %d has sequence_number %d", i, event.SequenceNumber)
+		}
+		if event.Type == "response.output_text.delta" {
+			text += event.Delta
+			continue
+		}
+		types = append(types, event.Type)
+	}
+	want := []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added",
+		"response.output_text.done", "response.content_part.done",
+		"response.output_item.done", "response.completed",
+	}
+	if strings.Join(types, " ") != strings.Join(want, " ") {
+		t.Fatalf("events = %v, want %v around the deltas", types, want)
+	}
+	if text != DefaultResponse {
+		t.Errorf("deltas join to %q, want the default reply", text)
+	}
+	if done := events[len(events)-4]; done.Text != text {
+		t.Errorf("output_text.done has %q, want the joined deltas", done.Text)
+	}
+	if last := events[len(events)-1].Response; last.Status != "completed" || last.Usage == nil {
+		t.Errorf("completed response = %+v, want status and usage", last)
+	}
+}
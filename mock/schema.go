@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// validateResponseFormat mirrors the real API, which refuses JSON mode unless
+// the conversation asks for JSON somewhere.
+func validateResponseFormat(req ChatCompletionRequest) *RequestError {
+	if req.ResponseFormat == nil {
+		return nil
+	}
+	switch req.ResponseFormat.Type {
+	case "json_schema":
+		if req.ResponseFormat.JSONSchema == nil {
+			return &RequestError{
+				Message: "Missing required parameter: 'response_format.json_schema'.",
+				Param:   "response_format.json_schema",
+				Code:    "missing_required_parameter",
+			}
+		}
+		return nil
+	case "json_object":
+	default:
+		return nil
+	}
+	for _, m := range req.Messages {
+		if strings.Contains(strings.ToLower(m.Content.String()), "json") {
+			return nil
+		}
+	}
+	return &RequestError{
+		Message: "'messages' must contain the word 'json' in some form, to use 'response_format' of type 'json_object'.",
+		Param:   "messages",
+	}
+}
+
+// toJSONObject returns content unchanged when it is already a JSON object and
+// otherwise wraps it in one.
+func toJSONObject(content string) string {
+	var object map[string]any
+	if json.Unmarshal([]byte(content), &object) == nil {
+		return content
+	}
+	return toJSON(map[string]string{"response": content})
+}
+
+// sampleFromSchema returns a value that satisfies the given JSON schema. Enums,
+// constants, nested objects and arrays, nullable types and anyOf/oneOf are
+// understood; anything else yields an empty object.
+func sampleFromSchema(raw json.RawMessage) any {
+	var schema map[string]any
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return map[string]any{}
+	}
+	return sampleValue(schema)
+}
+
+func sampleValue(schema map[string]any) any {
+	if value, ok := schema["const"]; ok {
+		return value
+	}
+	if values, ok := schema["enum"].([]any); ok && len(values) > 0 {
+		return values[0]
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if options, ok := schema[key].([]any); ok && len(options) > 0 {
+			if option, ok := options[0].(map[string]any); ok {
+				return sampleValue(option)
+			}
+		}
+	}
+
+	switch schemaType(schema) {
+	case "string":
+		return sampleString(schema)
+	case "number":
+		return 1.5
+	case "integer":
+		return 1
+	case "boolean":
+		return true
+	case "null":
+		return nil
+	case "array":
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return []any{}
+		}
+		return []any{sampleValue(items)}
+	}
+
+	object := map[string]any{}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, property := range properties {
+		if propertySchema, ok := property.(map[string]any); ok {
+			object[name] = sampleValue(propertySchema)
+		}
+	}
+	return object
+}
+
+// schemaType returns the schema's type, picking the first non-null type when
+// several are allowed.
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, option := range t {
+			if name, ok := option.(string); ok && name != "null" {
+				return name
+			}
+		}
+		return "null"
+	}
+	return ""
+}
+
+func sampleString(schema map[string]any) string {
+	switch schema["format"] {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "email":
+		return "user@example.com"
+	case "uri":
+		return "https://example.com"
+	case "uuid":
+		return "00000000-0000-4000-8000-000000000000"
+	}
+	return "sample"
+}
@@ -0,0 +1,70 @@
+package mock
+
+import (
+	"bufio"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// corpus is the built-in set of sentences used for varied replies.
+var corpus = []string{
+	"Sure, I can help with that.",
+	"Here is a quick overview of how it works.",
+	"The short answer is yes, but there are a few caveats worth knowing about.",
+	"Let me break this down step by step so it is easier to follow.",
+	"First, make sure your environment is configured correctly and that all dependencies are installed.",
+	"That depends on what you are trying to achieve.",
+	"In most cases the default settings are a good place to start.",
+	"If you run into problems, check the logs for anything unusual and try again with verbose output enabled.",
+	"Good question!",
+	"There are several ways to approach this, each with different trade-offs in speed, cost and complexity.",
+	"I hope this helps.",
+	"Feel free to ask if anything is unclear or if you would like more detail on a particular part.",
+}
+
+// variedResponse joins one to four corpus sentences chosen by rng, so both
+// the content and the length of replies change from request to request.
+func variedResponse(rng *rand.Rand) string {
+	sentences := make([]string, 1+rng.Intn(4))
+	for i := range sentences {
+		sentences[i] = corpus[rng.Intn(len(corpus))]
+	}
+	return strings.Join(sentences, " ")
+}
+
+// LoadSentences reads one reply per non-empty line from path.
+func LoadSentences(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sentences []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sentences = append(sentences, line)
+		}
+	}
+	return sentences, scanner.Err()
+}
+
+// pickSentence selects one of the configured Sentences by hashing the conversation,
+// so identical requests get identical replies while different ones vary.
+func (s *Server) pickSentence(req ChatCompletionRequest) (string, bool) {
+	if len(s.opts.Sentences) == 0 {
+		return "", false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(req.Model))
+	for _, m := range req.Messages {
+		h.Write([]byte{0})
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content.String()))
+	}
+	return s.opts.Sentences[h.Sum64()%uint64(len(s.opts.Sentences))], true
+}
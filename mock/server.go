@@ -0,0 +1,316 @@
+// Package mock implements a mock of the OpenAI HTTP API for testing clients.
+package mock
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Options configures a server created with NewServer. Zero fields take the
+// defaults noted on them.
+type Options struct {
+	// Latency is a fixed delay applied before every API response.
+	Latency time.Duration
+
+	// ModelLatency maps model names to an extra delay in milliseconds on top
+	// of Latency. Names match exactly unless they end in "*", which matches
+	// any model starting with the rest of the name.
+	ModelLatency map[string]int
+
+	// FailRate is the probability in [0, 1] that the rand_fail endpoint
+	// returns an error. The rand_all endpoint fails at half this rate.
+	FailRate float64
+
+	// FailCodes are the status codes the random and periodic failures pick
+	// from. Empty means 500.
+	FailCodes []int
+
+	// FailEvery makes every Nth API request fail with one of FailCodes, taken
+	// in turn. Zero disables it.
+	FailEvery int
+
+	// RetryAfter is the Retry-After value in seconds sent with simulated 429
+	// and 503 responses. Zero means DefaultRetryAfter.
+	RetryAfter int
+
+	// ChunkSize is the number of runes emitted per streaming chunk. Zero
+	// means DefaultStreamChunkSize.
+	ChunkSize int
+
+	// StreamMode is how streamed content is split: "rune" emits ChunkSize
+	// runes per chunk and "word" one word per chunk. Empty means "rune".
+	StreamMode string
+
+	// StreamInterval is the delay between streamed chunks.
+	StreamInterval time.Duration
+
+	// TTFT is the time to first token: the delay before the first chunk of a
+	// stream.
+	TTFT time.Duration
+
+	// KeepAliveInterval is how often a keep-alive comment is written while a
+	// stream waits for its first chunk. Zero disables them.
+	KeepAliveInterval time.Duration
+
+	// RuneDelay, when set, replaces StreamInterval with a delay per rune of
+	// the chunk just sent.
+	RuneDelay time.Duration
+
+	// MaxStreams caps the number of streaming responses served at once. Zero
+	// means no limit.
+	MaxStreams int
+
+	// MaxBodyBytes caps the size of request bodies. Zero means
+	// DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// EmbeddingDimensions is the default embedding vector length. Zero means
+	// DefaultEmbeddingDimensions.
+	EmbeddingDimensions int
+
+	// SystemFingerprint is reported in chat responses.
+	SystemFingerprint string
+
+	// CreatedOffset shifts every reported timestamp by that many seconds.
+	CreatedOffset int
+
+	// RateLimitRequests and RateLimitTokens are the per-minute limits reported
+	// in the x-ratelimit-* headers. Zero means DefaultRateLimitRequests and
+	// DefaultRateLimitTokens.
+	RateLimitRequests int
+	RateLimitTokens   int
+
+	// RateLimitRPM enables a token bucket per API key and per request user,
+	// refilled at that many requests per minute up to RateLimitCapacity. Zero
+	// disables rate limiting; a zero capacity means RateLimitRPM.
+	RateLimitRPM      int
+	RateLimitCapacity int
+
+	// BlockedUsers are request user values that always receive a 429.
+	BlockedUsers []string
+
+	// APIKey is the bearer token clients must send. Empty disables
+	// authentication.
+	APIKey string
+
+	// Organization is the OpenAI-Organization header value clients must send.
+	// Empty ignores the header.
+	Organization string
+
+	// AdminToken is the bearer token required by the /_mock endpoints that
+	// change state. Empty disables runtime configuration.
+	AdminToken string
+
+	// CORSOrigin is the Access-Control-Allow-Origin value. Empty means "*".
+	CORSOrigin string
+
+	// Models restricts requests to the listed model IDs and replaces the
+	// models served by /v1/models. Empty accepts any model.
+	Models []string
+
+	// MaxContext is the prompt token limit enforced on chat requests. Zero
+	// disables the check.
+	MaxContext int
+
+	// StrictRoles enables conversation structure checks on chat requests.
+	StrictRoles bool
+
+	// StrictFields rejects chat requests containing unknown fields.
+	StrictFields bool
+
+	// VariedResponses replaces DefaultResponse with sentences picked from a
+	// built-in corpus.
+	VariedResponses bool
+
+	// ResponsePrefix is prepended verbatim to every generated reply.
+	ResponsePrefix string
+
+	// RefusalTriggers are substrings that make the assistant refuse when found
+	// in a user message.
+	RefusalTriggers []string
+
+	// ModerationTriggers maps lowercase substrings to the moderation category
+	// they flag.
+	ModerationTriggers map[string]string
+
+	// TranscriptionText, when set, is returned for every transcription.
+	// Otherwise the text is derived from the file name.
+	TranscriptionText string
+
+	// PromptCacheMinTokens is the shortest message prefix reported as cached,
+	// and PromptCacheSize the number of prefixes remembered. Zero means
+	// DefaultPromptCacheMinTokens and DefaultPromptCacheSize.
+	PromptCacheMinTokens int
+	PromptCacheSize      int
+
+	// BatchDuration is how long a batch takes to complete after it is
+	// created.
+	BatchDuration time.Duration
+
+	// RequestHistorySize is the number of recent API requests kept for
+	// GET /_mock/requests. Zero means DefaultRequestHistorySize.
+	RequestHistorySize int
+
+	// CannedResponses maps the last user message of a conversation to the
+	// reply returned for it.
+	CannedResponses map[string]string
+
+	// ToolArguments maps tool names to the JSON arguments returned whenever
+	// the mock calls them.
+	ToolArguments map[string]string
+
+	// Templates are the response templates selected by model name or
+	// TemplateHeader, as returned by LoadTemplates.
+	Templates *template.Template
+
+	// Sentences are replies picked by hashing the conversation, as returned
+	// by LoadSentences.
+	Sentences []string
+
+	// Fixtures records or replays chat completions, as returned by
+	// LoadFixtures.
+	Fixtures *FixtureStore
+}
+
+// withDefaults fills in the zero fields that have a built-in default.
+func (o Options) withDefaults() Options {
+	if len(o.FailCodes) == 0 {
+		o.FailCodes = []int{http.StatusInternalServerError}
+	}
+	defaultInt(&o.RetryAfter, DefaultRetryAfter)
+	defaultInt(&o.ChunkSize, DefaultStreamChunkSize)
+	defaultInt(&o.EmbeddingDimensions, DefaultEmbeddingDimensions)
+	defaultInt(&o.RateLimitRequests, DefaultRateLimitRequests)
+	defaultInt(&o.RateLimitTokens, DefaultRateLimitTokens)
+	defaultInt(&o.RateLimitCapacity, o.RateLimitRPM)
+	defaultInt(&o.PromptCacheMinTokens, DefaultPromptCacheMinTokens)
+	defaultInt(&o.PromptCacheSize, DefaultPromptCacheSize)
+	defaultInt(&o.RequestHistorySize, DefaultRequestHistorySize)
+	if o.MaxBodyBytes <= 0 {
+		o.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if o.StreamMode == "" {
+		o.StreamMode = "rune"
+	}
+	if o.CORSOrigin == "" {
+		o.CORSOrigin = "*"
+	}
+	// Copy what handlers or /_mock/config may change, so the caller's maps
+	// and slices are left alone
+	o.FailCodes = slices.Clone(o.FailCodes)
+	o.CannedResponses = maps.Clone(o.CannedResponses)
+	if o.CannedResponses == nil {
+		o.CannedResponses = map[string]string{}
+	}
+	o.ToolArguments = maps.Clone(o.ToolArguments)
+	if o.ToolArguments == nil {
+		o.ToolArguments = map[string]string{}
+	}
+	return o
+}
+
+func defaultInt(n *int, def int) {
+	if *n <= 0 {
+		*n = def
+	}
+}
+
+// Server serves the mock API. Every server has its own settings and state,
+// so several can run side by side in one process.
+type Server struct {
+	// mu guards the settings that can be changed at runtime through
+	// POST /_mock/config or the Set methods: Latency, FailRate, FailCodes,
+	// FailEvery, ChunkSize, StreamInterval, TTFT, CannedResponses and
+	// ToolArguments. The rest of opts is fixed once the server is created.
+	mu   sync.RWMutex
+	opts Options
+
+	handler http.Handler
+	ready   atomic.Bool
+
+	models         []Model
+	streamSlots    chan struct{}
+	metrics        *metricsRegistry
+	usageWindow    *rateLimitWindow
+	requestLimiter *keyedLimiter
+	userLimiter    *keyedLimiter
+	promptCache    *prefixCache
+	batches        *batchStore
+	files          *fileStore
+	history        *requestRing
+
+	// apiRequests counts the requests seen by withPeriodicFailure since the
+	// server was created or last reset.
+	apiRequests atomic.Int64
+}
+
+// NewServer returns a server for the mock API configured by opts, ready to
+// mount on an http.Server or httptest.NewServer. It reports ready on /readyz
+// until SetReady(false) is called.
+func NewServer(opts Options) *Server {
+	opts = opts.withDefaults()
+	s := &Server{
+		opts:        opts,
+		models:      availableModels(opts.Models),
+		metrics:     newMetricsRegistry(),
+		usageWindow: &rateLimitWindow{requestLimit: opts.RateLimitRequests, tokenLimit: opts.RateLimitTokens},
+		promptCache: newPrefixCache(opts.PromptCacheSize, opts.PromptCacheMinTokens),
+		batches:     &batchStore{batches: map[string]Batch{}},
+		files:       &fileStore{files: map[string]storedFile{}},
+		history:     newRequestRing(opts.RequestHistorySize),
+	}
+	if opts.MaxStreams > 0 {
+		s.streamSlots = make(chan struct{}, opts.MaxStreams)
+	}
+	if opts.RateLimitRPM > 0 {
+		s.requestLimiter = newKeyedLimiter(opts.RateLimitCapacity, opts.RateLimitRPM)
+		s.userLimiter = newKeyedLimiter(opts.RateLimitCapacity, opts.RateLimitRPM)
+	}
+	s.ready.Store(true)
+
+	mux := http.NewServeMux()
+	s.handle(mux, "/v1/chat/completions", s.handleChatCompletion)
+	s.handleAzure(mux, "/openai/deployments/{deployment}/chat/completions", s.handleAzureChatCompletion)
+	s.handle(mux, "/rand_sleep/v1/chat/completions", s.handleRandomSleep)
+	s.handle(mux, "/rand_fail/v1/chat/completions", s.handleRandomFail)
+	s.handle(mux, "/rand_all/v1/chat/completions", s.handleRandom)
+	s.handle(mux, "/v1/models", s.handleListModels)
+	s.handle(mux, "/v1/models/{model}", s.handleRetrieveModel)
+	s.handle(mux, "/v1/embeddings", s.handleEmbeddings)
+	s.handle(mux, "/v1/completions", s.handleCompletion)
+	s.handle(mux, "/v1/responses", s.handleResponses)
+	s.handle(mux, "/v1/batches", s.handleCreateBatch)
+	s.handle(mux, "/v1/batches/{id}", s.handleRetrieveBatch)
+	s.handle(mux, "/v1/files", s.handleUploadFile)
+	s.handle(mux, "/v1/files/{id}", s.handleRetrieveFile)
+	s.handle(mux, "/v1/files/{id}/content", s.handleFileContent)
+	s.handle(mux, "/v1/moderations", s.handleModerations)
+	s.handle(mux, "/v1/images/generations", s.handleImageGeneration)
+	s.handle(mux, "/v1/audio/transcriptions", s.handleTranscription)
+	s.handle(mux, "/v1/audio/speech", s.handleSpeech)
+
+	// Probes and the /_mock endpoints bypass auth, latency and rate
+	// limiting, but are still counted in the metrics
+	for pattern, h := range map[string]http.HandlerFunc{
+		"/healthz":        handleHealthz,
+		"/readyz":         s.handleReadyz,
+		"/metrics":        s.handleMetrics,
+		"/_mock/config":   s.handleMockConfig,
+		"/_mock/reset":    s.handleMockReset,
+		"/_mock/requests": s.handleMockRequests,
+	} {
+		mux.HandleFunc(pattern, s.withMetrics(pattern, h))
+	}
+
+	s.handler = withRequestID(withAccessLog(withGzip(s.withCORS(s.withBodyLimit(mux)))))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,120 @@
+package mock
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer starts a mock server configured by opts, closed when the test
+// ends.
+func newTestServer(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(NewServer(opts))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends a request with body and the given headers and returns the response
+// with its body read.
+func do(t *testing.T, method, url, body string, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+// decode unmarshals data into v, failing the test on invalid JSON.
+func decode(t *testing.T, data []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+}
+
+// errorCode returns the code of an error response body.
+func errorCode(t *testing.T, data []byte) string {
+	t.Helper()
+	var resp ErrorResponse
+	decode(t, data, &resp)
+	if resp.Error.Code == nil {
+		return ""
+	}
+	return *resp.Error.Code
+}
+
+// readChunks decodes the data events of a chat completion stream, checking
+// that it ends with [DONE].
+func readChunks(t *testing.T, data []byte) []ChatCompletionChunk {
+	t.Helper()
+	var chunks []ChatCompletionChunk
+	done := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if payload == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk ChatCompletionChunk
+		decode(t, []byte(payload), &chunk)
+		chunks = append(chunks, chunk)
+	}
+	if !done {
+		t.Fatalf("stream did not end with [DONE]:\n%s", data)
+	}
+	return chunks
+}
+
+func TestServersAreIsolated(t *testing.T) {
+	a := NewServer(Options{})
+	a.SetCannedResponse("ping", "pong")
+	tsA := httptest.NewServer(a)
+	defer tsA.Close()
+	tsB := newTestServer(t, Options{})
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"ping"}]}`
+	var respA, respB ChatCompletionResponse
+	_, data := do(t, http.MethodPost, tsA.URL+"/v1/chat/completions", body, nil)
+	decode(t, data, &respA)
+	_, data = do(t, http.MethodPost, tsB.URL+"/v1/chat/completions", body, nil)
+	decode(t, data, &respB)
+
+	if got := respA.Choices[0].Message.Content.String(); got != "pong" {
+		t.Errorf("server A replied %q, want the canned response", got)
+	}
+	if got := respB.Choices[0].Message.Content.String(); got == "pong" {
+		t.Errorf("server B replied with server A's canned response")
+	}
+
+	var history RequestHistoryList
+	_, data = do(t, http.MethodGet, tsB.URL+"/_mock/requests", "", nil)
+	decode(t, data, &history)
+	if len(history.Data) != 1 {
+		t.Errorf("server B recorded %d requests, want only its own 1", len(history.Data))
+	}
+}
@@ -0,0 +1,169 @@
+package mock
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// keepAliveComment is an SSE comment line, ignored by clients but enough to
+// stop proxies from closing an idle stream.
+const keepAliveComment = ": keep-alive\n\n"
+
+// acquireStream reserves a slot for a new stream, returning false when all
+// MaxStreams slots are taken. The returned release frees the slot.
+func (s *Server) acquireStream() (release func(), ok bool) {
+	if s.streamSlots != nil {
+		select {
+		case s.streamSlots <- struct{}{}:
+		default:
+			return nil, false
+		}
+	}
+	s.metrics.streaming.Add(1)
+	return func() {
+		s.metrics.streaming.Add(-1)
+		if s.streamSlots != nil {
+			<-s.streamSlots
+		}
+	}, true
+}
+
+// writeStreamLimited rejects a stream with a 503 when MaxStreams is reached.
+func (s *Server) writeStreamLimited(w http.ResponseWriter) {
+	s.writeStatusError(w, http.StatusServiceUnavailable)
+}
+
+// chunkStream writes the chunks of one streamed response, counting them so
+// the totals can be logged once the stream completes.
+type chunkStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	log     *slog.Logger
+	id      string
+	created int64
+	start   time.Time
+	chunks  int
+
+	// strict adds id: and event: fields to every event, for generic
+	// EventSource clients. It is enabled per request with ?sse=strict.
+	strict bool
+
+	// keepAlive is the interval between keep-alive comments while waiting
+	// for the first chunk; zero disables them.
+	keepAlive time.Duration
+
+	// breakAfter cuts the stream off after that many chunks, set per request
+	// with ?break_after=. With ?break_mode=error an SSE error event is
+	// written before the connection is dropped.
+	breakAfter int
+	breakError bool
+}
+
+func (s *Server) newChunkStream(w http.ResponseWriter, r *http.Request, log *slog.Logger, id string, created int64) *chunkStream {
+	return &chunkStream{
+		ctx:       r.Context(),
+		w:         w,
+		log:       log,
+		id:        id,
+		created:   created,
+		start:     time.Now(),
+		strict:    r.URL.Query().Get("sse") == "strict",
+		keepAlive: s.keepAliveInterval(r),
+
+		breakAfter: parsePositiveInt(r.URL.Query().Get("break_after"), 0),
+		breakError: r.URL.Query().Get("break_mode") == "error",
+	}
+}
+
+// wait pauses for d before the first chunk, writing keep-alive comments every
+// keepAlive interval meanwhile. It reports false if the client went away.
+func (s *chunkStream) wait(d time.Duration) bool {
+	if s.keepAlive <= 0 || d <= s.keepAlive {
+		return sleepCtx(s.ctx, d)
+	}
+	deadline := time.Now().Add(d)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return true
+		}
+		if !sleepCtx(s.ctx, min(remaining, s.keepAlive)) {
+			return false
+		}
+		if time.Until(deadline) > 0 {
+			s.w.Write([]byte(keepAliveComment))
+			s.flush()
+		}
+	}
+}
+
+func (s *chunkStream) write(chunk interface{}) {
+	s.writeNamed("", chunk)
+}
+
+// writeNamed writes chunk as an event of the given type, as the Responses API
+// streams. An empty name writes a plain data-only event.
+func (s *chunkStream) writeNamed(name string, chunk interface{}) {
+	s.writeEvent(name, toJSON(chunk))
+	s.log.Info("writeChunk", "event", name, "chunk", chunk)
+	s.chunks++
+	if s.chunks == s.breakAfter {
+		s.abort()
+	}
+}
+
+// abort drops the connection mid-stream, without the [DONE] sentinel.
+func (s *chunkStream) abort() {
+	if s.breakError {
+		code := "stream_interrupted"
+		s.writeEvent("error", toJSON(ErrorResponse{Error: ErrorDetail{
+			Message: "The server had an error while processing your request. Sorry about that!",
+			Type:    "server_error",
+			Code:    &code,
+		}}))
+	}
+	s.log.Info("stream broken", "id", s.id, "chunks", s.chunks, "error_event", s.breakError)
+	panic(http.ErrAbortHandler)
+}
+
+// done writes the [DONE] sentinel and logs how long the stream took.
+func (s *chunkStream) done() {
+	s.writeEvent("", "[DONE]")
+	s.complete()
+}
+
+// complete logs how long the stream took.
+func (s *chunkStream) complete() {
+	s.log.Info("stream complete",
+		"id", s.id,
+		"created", s.created,
+		"chunks", s.chunks,
+		"duration", time.Since(s.start),
+	)
+}
+
+// writeEvent writes data as one server-sent event and flushes it. Unnamed
+// events only carry an event: field in strict mode.
+func (s *chunkStream) writeEvent(name, data string) {
+	event := "data: " + data + "\n\n"
+	if name == "" && s.strict {
+		name = "message"
+	}
+	if name != "" {
+		event = "event: " + name + "\n" + event
+	}
+	if s.strict {
+		event = "id: " + strconv.Itoa(s.chunks+1) + "\n" + event
+	}
+	s.w.Write([]byte(event))
+	s.flush()
+}
+
+func (s *chunkStream) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
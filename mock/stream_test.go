@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// postStream posts a streamed chat request to url and returns whatever body
+// arrived, along with the error that ended it.
+func postStream(t *testing.T, url string) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url,
+		strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func TestBreakAfter(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	body, err := postStream(t, ts.URL+"/v1/chat/completions?break_after=3")
+	if err == nil {
+		t.Error("the stream ended cleanly, want the connection dropped")
+	}
+	if got := strings.Count(body, "data: "); got != 3 {
+		t.Errorf("got %d events before the break, want 3:\n%s", got, body)
+	}
+	if strings.Contains(body, "[DONE]") {
+		t.Error("a broken stream sent [DONE]")
+	}
+
+	body, _ = postStream(t, ts.URL+"/v1/chat/completions?break_after=3&break_mode=error")
+	if !strings.Contains(body, "event: error\n") || !strings.Contains(body, "stream_interrupted") {
+		t.Errorf("break_mode=error did not send an error event:\n%s", body)
+	}
+}
+
+func TestBrokenStreamsAreRecorded(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	postStream(t, ts.URL+"/v1/chat/completions?break_after=2")
+
+	if page := scrape(t, ts.URL); !strings.Contains(page, `mock_requests_total{path="/v1/chat/completions",status="200"} 1`) {
+		t.Errorf("the broken stream was not counted:\n%s", page)
+	}
+}
@@ -0,0 +1,69 @@
+package mock
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateHeader names the response template to render for a request,
+// overriding the template selected by model name.
+const TemplateHeader = "x-mock-template"
+
+// TemplateData is passed to response templates when they are rendered.
+type TemplateData struct {
+	Model           string
+	Messages        []Message
+	LastUserMessage string
+}
+
+// LoadTemplates parses every *.tmpl file in dir. Each template is named after
+// its file without the extension.
+func LoadTemplates(dir string) (*template.Template, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	root := template.New("")
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if _, err := root.New(name).Parse(string(text)); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// renderTemplate renders the template selected by req's template header or
+// model name. It reports false when no template matches or rendering fails.
+func (s *Server) renderTemplate(log *slog.Logger, req ChatCompletionRequest) (string, bool) {
+	if s.opts.Templates == nil {
+		return "", false
+	}
+	tmpl := s.opts.Templates.Lookup(req.Template)
+	if tmpl == nil || req.Template == "" {
+		tmpl = s.opts.Templates.Lookup(req.Model)
+	}
+	if tmpl == nil {
+		return "", false
+	}
+
+	data := TemplateData{
+		Model:           req.Model,
+		Messages:        req.Messages,
+		LastUserMessage: lastUserMessage(req.Messages),
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		log.Error("render template", "template", tmpl.Name(), "err", err)
+		return "", false
+	}
+	// Template files usually end with a newline that isn't part of the reply
+	return strings.TrimSuffix(out.String(), "\n"), true
+}
@@ -0,0 +1,55 @@
+package mock
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"gpt-4o.tmpl": "{{.Model}} heard: {{.LastUserMessage}}\n",
+		"terse.tmpl":  "ok ({{len .Messages}} messages)",
+		"broken.tmpl": "{{index .Messages 5}}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, Options{Templates: templates})
+
+	reply := func(model, template string) string {
+		t.Helper()
+		header := map[string]string{}
+		if template != "" {
+			header[TemplateHeader] = template
+		}
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+			`{"model":"`+model+`","messages":[{"role":"system","content":"be nice"},{"role":"user","content":"hello there"}]}`, header)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d: %s", resp.StatusCode, data)
+		}
+		var completion ChatCompletionResponse
+		decode(t, data, &completion)
+		return completion.Choices[0].Message.Content.String()
+	}
+
+	if got, want := reply("gpt-4o", ""), "gpt-4o heard: hello there"; got != want {
+		t.Errorf("model template: %q, want %q", got, want)
+	}
+	if got, want := reply("gpt-4o", "terse"), "ok (2 messages)"; got != want {
+		t.Errorf("header template: %q, want %q", got, want)
+	}
+	if got := reply("gpt-4o-mini", ""); got != DefaultResponse {
+		t.Errorf("no matching template: %q, want the default reply", got)
+	}
+	if got := reply("gpt-4o-mini", "broken"); got != DefaultResponse {
+		t.Errorf("failing template: %q, want the default reply", got)
+	}
+}
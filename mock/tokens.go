@@ -0,0 +1,107 @@
+package mock
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Use the encodings embedded in the binary rather than downloading them
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// reasoningModelPrefixes name the models tiktoken-go does not know about but
+// which use the o200k_base encoding.
+var reasoningModelPrefixes = []string{"o1", "o3", "o4"}
+
+// lazyEncoding builds a BPE encoding the first time it is needed. Building
+// one takes a while and a lot of memory, so each is built at most once and
+// shared by every model that uses it.
+type lazyEncoding struct {
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+}
+
+func (e *lazyEncoding) get(name string) *tiktoken.Tiktoken {
+	e.once.Do(func() {
+		enc, err := tiktoken.GetEncoding(name)
+		if err == nil {
+			e.enc = enc
+		}
+	})
+	return e.enc
+}
+
+// encodings holds one lazyEncoding per encoding name. The map itself is never
+// written, so it needs no lock.
+var encodings = map[string]*lazyEncoding{
+	tiktoken.MODEL_O200K_BASE:  {},
+	tiktoken.MODEL_CL100K_BASE: {},
+	tiktoken.MODEL_P50K_BASE:   {},
+	tiktoken.MODEL_P50K_EDIT:   {},
+	tiktoken.MODEL_R50K_BASE:   {},
+}
+
+// encodingName returns the name of the encoding model uses, or "" when model
+// is not a known OpenAI model. Versioned names such as gpt-4o-2024-05-13
+// match the longest known prefix.
+func encodingName(model string) string {
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name
+	}
+	match, name := "", ""
+	for prefix, encoding := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(match) {
+			match, name = prefix, encoding
+		}
+	}
+	if name != "" {
+		return name
+	}
+	for _, prefix := range reasoningModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return tiktoken.MODEL_O200K_BASE
+		}
+	}
+	return ""
+}
+
+// encoderFor returns the BPE encoding of model, or nil when model is not a
+// known OpenAI model.
+func encoderFor(model string) *tiktoken.Tiktoken {
+	name := encodingName(model)
+	if e, ok := encodings[name]; ok {
+		return e.get(name)
+	}
+	return nil
+}
+
+// countTokens returns the number of tokens in s for model, using its BPE
+// encoding for known models and falling back to counting words otherwise.
+func countTokens(model, s string) int {
+	if enc := encoderFor(model); enc != nil {
+		return len(enc.Encode(s, nil, nil))
+	}
+	return len(splitIntoWords(s))
+}
+
+// truncateTokens cuts s to its first n tokens for model, or its first n words
+// when the model has no known encoding. It reports whether s was cut.
+func truncateTokens(model, s string, n int) (string, bool) {
+	n = max(n, 0)
+	if enc := encoderFor(model); enc != nil {
+		tokens := enc.Encode(s, nil, nil)
+		if len(tokens) <= n {
+			return s, false
+		}
+		return enc.Decode(tokens[:n]), true
+	}
+	words := splitIntoWords(s)
+	if len(words) <= n {
+		return s, false
+	}
+	return strings.Join(words[:n], " "), true
+}
@@ -0,0 +1,172 @@
+package mock
+
+import (
+	"encoding/json"
+	"math/rand"
+)
+
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type ToolCall struct {
+	// Index is only set on streamed tool call deltas.
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// ToolChoice is either a mode ("none", "auto" or "required") or a specific
+// function the model must call.
+type ToolChoice struct {
+	Mode     string
+	Function string
+}
+
+// forcesCall reports whether the choice requires a tool call, by naming a
+// function or with the "required" mode. A nil choice does not.
+func (c *ToolChoice) forcesCall() bool {
+	return c != nil && (c.Function != "" || c.Mode == "required")
+}
+
+func (c ToolChoice) MarshalJSON() ([]byte, error) {
+	if c.Function != "" {
+		return json.Marshal(map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": c.Function},
+		})
+	}
+	return json.Marshal(c.Mode)
+}
+
+func (c *ToolChoice) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Mode); err == nil {
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	c.Function = named.Function.Name
+	return nil
+}
+
+// SetToolArguments registers the JSON arguments returned whenever the mock
+// calls the tool named name.
+func (s *Server) SetToolArguments(name, arguments string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.ToolArguments[name] = arguments
+}
+
+// generateToolCalls returns the tool calls the assistant makes for req, or nil
+// when no tool should be called. A tool named by tool_choice is called alone;
+// otherwise every tool is called at once unless parallel_tool_calls is false,
+// in which case only the first one is. Once the conversation ends with tool
+// results the assistant answers in text, unless tool_choice forces a call.
+func (s *Server) generateToolCalls(req ChatCompletionRequest, rng *rand.Rand) []ToolCall {
+	if len(req.Tools) == 0 || (req.ToolChoice != nil && req.ToolChoice.Mode == "none") {
+		return nil
+	}
+	if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == "tool" && !req.ToolChoice.forcesCall() {
+		return nil
+	}
+
+	tools := req.Tools
+	if req.ParallelToolCalls != nil && !*req.ParallelToolCalls {
+		tools = tools[:1]
+	}
+	if req.ToolChoice != nil && req.ToolChoice.Function != "" {
+		for _, t := range req.Tools {
+			if t.Function.Name == req.ToolChoice.Function {
+				tools = []Tool{t}
+				break
+			}
+		}
+	}
+
+	calls := make([]ToolCall, len(tools))
+	for i, tool := range tools {
+		calls[i] = ToolCall{
+			ID:   "call_" + randomString(rng, 24),
+			Type: "function",
+			Function: FunctionCall{
+				Name:      tool.Function.Name,
+				Arguments: s.toolCallArguments(tool.Function),
+			},
+		}
+	}
+	return calls
+}
+
+// toolCallArguments returns the canned arguments registered for fn, falling
+// back to arguments synthesized from its parameter schema.
+func (s *Server) toolCallArguments(fn FunctionDefinition) string {
+	s.mu.RLock()
+	arguments, ok := s.opts.ToolArguments[fn.Name]
+	s.mu.RUnlock()
+	if ok {
+		return arguments
+	}
+	return toJSON(sampleFromSchema(fn.Parameters))
+}
+
+// wholeToolCallDelta returns a single delta carrying every call complete with
+// its index, for streams sent in one chunk.
+func wholeToolCallDelta(calls []ToolCall) DeltaMessage {
+	indexed := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		index := i
+		call.Index = &index
+		indexed[i] = call
+	}
+	return DeltaMessage{ToolCalls: indexed}
+}
+
+// toolCallDeltas splits calls into streaming deltas: the first delta for each
+// call carries its index, ID and function name, and the following deltas append
+// fragments of the arguments.
+func toolCallDeltas(calls []ToolCall, chunkSize int) []DeltaMessage {
+	var deltas []DeltaMessage
+	for i, call := range calls {
+		index := i
+		deltas = append(deltas, DeltaMessage{
+			ToolCalls: []ToolCall{
+				{
+					Index:    &index,
+					ID:       call.ID,
+					Type:     call.Type,
+					Function: FunctionCall{Name: call.Function.Name},
+				},
+			},
+		})
+		for _, fragment := range splitRunes(call.Function.Arguments, chunkSize) {
+			deltas = append(deltas, DeltaMessage{
+				ToolCalls: []ToolCall{
+					{
+						Index:    &index,
+						Function: FunctionCall{Arguments: fragment},
+					},
+				},
+			})
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,159 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const weatherTools = `"tools":[` +
+	`{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}},` +
+	`{"type":"function","function":{"name":"get_time","parameters":{"type":"object","properties":{"zone":{"type":"string"}}}}}]`
+
+// toolNames returns the function names of calls.
+func toolNames(calls []ToolCall) []string {
+	names := make([]string, len(calls))
+	for i, call := range calls {
+		names[i] = call.Function.Name
+	}
+	return names
+}
+
+func TestToolCalls(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	choice := chat(t, ts.URL, weatherTools).Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", choice.FinishReason)
+	}
+	if got := toolNames(choice.Message.ToolCalls); len(got) != 2 || got[0] != "get_weather" || got[1] != "get_time" {
+		t.Fatalf("called %v, want both tools", got)
+	}
+	for _, call := range choice.Message.ToolCalls {
+		if call.ID == "" || call.Type != "function" {
+			t.Errorf("call %+v is missing its id or type", call)
+		}
+		var args map[string]any
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			t.Errorf("%s arguments %q are not a JSON object", call.Function.Name, call.Function.Arguments)
+		}
+	}
+	var args struct{ City *string }
+	json.Unmarshal([]byte(choice.Message.ToolCalls[0].Function.Arguments), &args)
+	if args.City == nil {
+		t.Errorf("get_weather arguments lack the required city")
+	}
+}
+
+func TestToolChoice(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	choice := chat(t, ts.URL, weatherTools+`,"tool_choice":"none"`).Choices[0]
+	if len(choice.Message.ToolCalls) != 0 || choice.FinishReason != "stop" {
+		t.Errorf("tool_choice none: calls %v, finish %q", toolNames(choice.Message.ToolCalls), choice.FinishReason)
+	}
+
+	choice = chat(t, ts.URL, weatherTools+`,"tool_choice":{"type":"function","function":{"name":"get_time"}}`).Choices[0]
+	if got := toolNames(choice.Message.ToolCalls); len(got) != 1 || got[0] != "get_time" {
+		t.Errorf("named tool_choice: called %v, want get_time", got)
+	}
+
+	for _, params := range []string{
+		weatherTools + `,"tool_choice":{"type":"function","function":{"name":"get_news"}}`,
+		`"tool_choice":{"type":"function","function":{"name":"get_time"}}`,
+	} {
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+			`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],`+params+`}`, nil)
+		var e ErrorResponse
+		decode(t, data, &e)
+		if resp.StatusCode != http.StatusBadRequest || e.Error.Param == nil || *e.Error.Param != "tool_choice" {
+			t.Errorf("tool_choice naming a missing tool: status %d: %s, want 400 on tool_choice", resp.StatusCode, data)
+		}
+	}
+}
+
+func TestToolResultsAnsweredInText(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	messages := `"messages":[` +
+		`{"role":"user","content":"weather?"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"call_1","content":"sunny"}]`
+
+	post := func(params string) ChatCompletionChoice {
+		resp, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+			`{"model":"gpt-4o",`+messages+`,`+weatherTools+params+`}`, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status %d: %s", resp.StatusCode, data)
+		}
+		var completion ChatCompletionResponse
+		decode(t, data, &completion)
+		return completion.Choices[0]
+	}
+
+	if choice := post(""); len(choice.Message.ToolCalls) != 0 || choice.FinishReason != "stop" {
+		t.Errorf("after tool results: calls %v, finish %q, want a text answer", toolNames(choice.Message.ToolCalls), choice.FinishReason)
+	}
+	if choice := post(`,"tool_choice":"required"`); choice.FinishReason != "tool_calls" {
+		t.Errorf("tool_choice required: finish %q, want tool_calls", choice.FinishReason)
+	}
+}
+
+func TestSetToolArguments(t *testing.T) {
+	s := NewServer(Options{})
+	s.SetToolArguments("get_weather", `{"city":"Paris"}`)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	choice := chat(t, ts.URL, weatherTools).Choices[0]
+	if got := choice.Message.ToolCalls[0].Function.Arguments; got != `{"city":"Paris"}` {
+		t.Errorf("arguments = %q, want the registered ones", got)
+	}
+}
+
+func TestStreamedToolCalls(t *testing.T) {
+	ts := newTestServer(t, Options{ChunkSize: 3})
+	_, data := do(t, http.MethodPost, ts.URL+"/v1/chat/completions",
+		`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}],`+weatherTools+`}`, nil)
+
+	// Reassemble the calls the way clients do, by index
+	var calls []ToolCall
+	finish := ""
+	for _, chunk := range readChunks(t, data) {
+		for _, delta := range chunk.Choices[0].Delta.ToolCalls {
+			if delta.Index == nil {
+				t.Fatalf("tool call delta %+v has no index", delta)
+			}
+			if *delta.Index == len(calls) {
+				calls = append(calls, ToolCall{ID: delta.ID, Type: delta.Type, Function: FunctionCall{Name: delta.Function.Name}})
+			}
+			calls[*delta.Index].Function.Arguments += delta.Function.Arguments
+		}
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			finish = reason
+		}
+	}
+
+	if finish != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", finish)
+	}
+	if got := toolNames(calls); len(got) != 2 || got[0] != "get_weather" || got[1] != "get_time" {
+		t.Fatalf("called %v, want both tools", got)
+	}
+	for _, call := range calls {
+		if call.ID == "" {
+			t.Errorf("%s has no id", call.Function.Name)
+		}
+		if !json.Valid([]byte(call.Function.Arguments)) {
+			t.Errorf("%s arguments %q do not reassemble into JSON", call.Function.Name, call.Function.Arguments)
+		}
+	}
+}
+
+func TestParallelToolCalls(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	choice := chat(t, ts.URL, weatherTools+`,"parallel_tool_calls":false`).Choices[0]
+	if got := toolNames(choice.Message.ToolCalls); len(got) != 1 || got[0] != "get_weather" {
+		t.Errorf("called %v, want only the first tool", got)
+	}
+}
@@ -0,0 +1,286 @@
+package mock
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// validRoles are the message roles accepted by the chat completions API.
+var validRoles = map[string]bool{
+	"system":    true,
+	"developer": true,
+	"user":      true,
+	"assistant": true,
+	"tool":      true,
+}
+
+// RequestError describes why a request was rejected.
+type RequestError struct {
+	Message string
+	Param   string
+	Code    string
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// validateMessages checks that messages is present and every message has a
+// known role and content. Only assistant messages that made tool calls or
+// refused may have null content, as when a client replays such a turn.
+func validateMessages(messages []Message) *RequestError {
+	if messages == nil {
+		return &RequestError{
+			Message: "Missing required parameter: 'messages'.",
+			Param:   "messages",
+			Code:    "missing_required_parameter",
+		}
+	}
+	if len(messages) == 0 {
+		return &RequestError{
+			Message: "[] is too short - 'messages'",
+			Param:   "messages",
+			Code:    "empty_array",
+		}
+	}
+	for i, m := range messages {
+		if !validRoles[m.Role] {
+			return &RequestError{
+				Message: fmt.Sprintf("Invalid value: '%s' at messages[%d].role. Supported values are: 'system', 'developer', 'user', 'assistant', and 'tool'.", m.Role, i),
+				Param:   "messages",
+				Code:    "invalid_value",
+			}
+		}
+		if m.Content == nil && (m.Role != "assistant" || len(m.ToolCalls) == 0 && m.Refusal == nil) {
+			return &RequestError{
+				Message: fmt.Sprintf("Invalid value for 'content': expected a string, got null at messages[%d].content.", i),
+				Param:   fmt.Sprintf("messages.[%d].content", i),
+				Code:    "invalid_value",
+			}
+		}
+	}
+	return nil
+}
+
+// validateRoleOrder rejects malformed conversations when StrictRoles is set.
+func (s *Server) validateRoleOrder(messages []Message) *RequestError {
+	if !s.opts.StrictRoles {
+		return nil
+	}
+	conversationStarted := false
+	pendingCalls := map[string]bool{}
+	for i, m := range messages {
+		switch m.Role {
+		case "system", "developer":
+			if conversationStarted {
+				return &RequestError{
+					Message: fmt.Sprintf("Invalid parameter: messages[%d] with role '%s' must come before any user, assistant or tool messages.", i, m.Role),
+					Param:   fmt.Sprintf("messages.[%d].role", i),
+					Code:    "invalid_value",
+				}
+			}
+		case "tool":
+			if !pendingCalls[m.ToolCallID] {
+				return &RequestError{
+					Message: "Invalid parameter: messages with role 'tool' must be a response to a preceeding message with 'tool_calls'.",
+					Param:   fmt.Sprintf("messages.[%d].role", i),
+					Code:    "invalid_value",
+				}
+			}
+			delete(pendingCalls, m.ToolCallID)
+		default:
+			if len(pendingCalls) > 0 {
+				return &RequestError{
+					Message: "An assistant message with 'tool_calls' must be followed by tool messages responding to each 'tool_call_id'.",
+					Param:   fmt.Sprintf("messages.[%d].role", i),
+					Code:    "invalid_value",
+				}
+			}
+			conversationStarted = true
+			for _, call := range m.ToolCalls {
+				pendingCalls[call.ID] = true
+			}
+		}
+	}
+	return nil
+}
+
+// validateContextLength rejects prompts longer than MaxContext tokens. It is a
+// no-op when MaxContext is zero.
+func (s *Server) validateContextLength(model string, messages []Message) *RequestError {
+	if s.opts.MaxContext == 0 {
+		return nil
+	}
+	tokens := calculateUsage(model, messages, "").PromptTokens
+	if tokens <= s.opts.MaxContext {
+		return nil
+	}
+	return &RequestError{
+		Message: fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in %d tokens. Please reduce the length of the messages.", s.opts.MaxContext, tokens),
+		Param:   "messages",
+		Code:    "context_length_exceeded",
+	}
+}
+
+// validateChatCompletion checks the messages and parameters of a chat request,
+// returning the first problem found. The model is checked separately since an
+// unknown model is a 404 rather than a 400.
+func (s *Server) validateChatCompletion(req ChatCompletionRequest) *RequestError {
+	if err := validateMessages(req.Messages); err != nil {
+		return err
+	}
+	if err := s.validateRoleOrder(req.Messages); err != nil {
+		return err
+	}
+	if err := s.validateContextLength(req.Model, req.Messages); err != nil {
+		return err
+	}
+	if err := validateChoiceCount(req.N); err != nil {
+		return err
+	}
+	if err := validateMaxTokens(req); err != nil {
+		return err
+	}
+	if err := validateStop(req.Stop); err != nil {
+		return err
+	}
+	if err := validateLogProbs(req); err != nil {
+		return err
+	}
+	if err := validateLogitBias(req.LogitBias); err != nil {
+		return err
+	}
+	if err := validateToolChoice(req); err != nil {
+		return err
+	}
+	return validateResponseFormat(req)
+}
+
+// validateToolChoice checks that a function named by tool_choice is one of
+// the tools.
+func validateToolChoice(req ChatCompletionRequest) *RequestError {
+	if req.ToolChoice == nil || req.ToolChoice.Function == "" {
+		return nil
+	}
+	for _, t := range req.Tools {
+		if t.Function.Name == req.ToolChoice.Function {
+			return nil
+		}
+	}
+	return &RequestError{
+		Message: fmt.Sprintf("Invalid value for 'tool_choice': function '%s' is not among the 'tools'.", req.ToolChoice.Function),
+		Param:   "tool_choice",
+		Code:    "invalid_value",
+	}
+}
+
+// maxChoices is the largest n a chat request may ask for.
+const maxChoices = 128
+
+// validateChoiceCount checks that n, when set, is between 1 and maxChoices.
+func validateChoiceCount(n *int) *RequestError {
+	switch {
+	case n == nil:
+		return nil
+	case *n < 1:
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid 'n': integer below minimum value. Expected a value >= 1, but got %d instead.", *n),
+			Param:   "n",
+			Code:    "integer_below_min_value",
+		}
+	case *n > maxChoices:
+		return &RequestError{
+			Message: fmt.Sprintf("Invalid 'n': integer above maximum value. Expected a value <= %d, but got %d instead.", maxChoices, *n),
+			Param:   "n",
+			Code:    "integer_above_max_value",
+		}
+	}
+	return nil
+}
+
+// validateMaxTokens checks that max_completion_tokens and max_tokens, when
+// set, allow at least one token.
+func validateMaxTokens(req ChatCompletionRequest) *RequestError {
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"max_completion_tokens", req.MaxCompletionTokens},
+		{"max_tokens", req.MaxTokens},
+	} {
+		if p.value != nil && *p.value < 1 {
+			return &RequestError{
+				Message: fmt.Sprintf("Invalid '%s': integer below minimum value. Expected a value >= 1, but got %d instead.", p.name, *p.value),
+				Param:   p.name,
+				Code:    "integer_below_min_value",
+			}
+		}
+	}
+	return nil
+}
+
+// validateStop enforces the API limit of four stop sequences.
+func validateStop(stop []string) *RequestError {
+	if len(stop) <= 4 {
+		return nil
+	}
+	return &RequestError{
+		Message: fmt.Sprintf("Invalid 'stop': array too long. Expected an array with maximum length 4, but got an array with length %d instead.", len(stop)),
+		Param:   "stop",
+		Code:    "array_above_max_length",
+	}
+}
+
+// validateLogitBias checks that logit_bias maps token IDs to biases between
+// -100 and 100. The bias itself is not applied.
+func validateLogitBias(bias map[string]float64) *RequestError {
+	for key, value := range bias {
+		if id, err := strconv.Atoi(key); err != nil || id < 0 {
+			return &RequestError{
+				Message: fmt.Sprintf("Invalid key in 'logit_bias': %s. You should only be submitting non-negative integers.", key),
+				Param:   "logit_bias",
+				Code:    "invalid_value",
+			}
+		}
+		if value < -100 || value > 100 {
+			return &RequestError{
+				Message: fmt.Sprintf("Invalid value for 'logit_bias': %g. Values must be between -100 and 100.", value),
+				Param:   "logit_bias",
+				Code:    "invalid_value",
+			}
+		}
+	}
+	return nil
+}
+
+// requireJSON checks that r declares a JSON body, writing a 415 and returning
+// false when it does not. Parameters such as charset are allowed.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/json" {
+		return true
+	}
+	writeError(w, http.StatusUnsupportedMediaType,
+		fmt.Sprintf("Invalid Content-Type header (%s), expected application/json.", contentType),
+		"invalid_request_error", "unsupported_media_type")
+	return false
+}
+
+// body returns the error envelope writeRequestError writes for e.
+func (e *RequestError) body() ErrorResponse {
+	detail := ErrorDetail{Message: e.Message, Type: "invalid_request_error"}
+	if e.Param != "" {
+		detail.Param = &e.Param
+	}
+	if e.Code != "" {
+		detail.Code = &e.Code
+	}
+	return ErrorResponse{Error: detail}
+}
+
+// writeRequestError writes err as a 400 invalid_request_error.
+func writeRequestError(w http.ResponseWriter, err *RequestError) {
+	writeParamError(w, http.StatusBadRequest, err.Message, "invalid_request_error", err.Param, err.Code)
+}